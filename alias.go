@@ -0,0 +1,103 @@
+package main
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+)
+
+// An alias counts a term, such as "#ai" or "artificial intelligence", under
+// a canonical term such as "ai".
+type Alias struct {
+  Uid int `json:"id"`
+  Term string `json:"term"`
+  Canonical string `json:"canonical"`
+}
+
+type Aliases []Alias
+
+// Builds the aliases of a canonical term, lowercasing and trimming them and
+// skipping blanks, repeats and the canonical term itself. Fails if the
+// canonical term is blank.
+func NewAliases(canonical string, terms []string) (aliases Aliases, err error) {
+  canonical = strings.ToLower(strings.TrimSpace(canonical))
+  if canonical == "" {
+    return nil, fmt.Errorf("An alias needs the canonical term it counts under")
+  }
+
+  seen := map[string]bool {canonical: true}
+  aliases = Aliases {}
+  for _, term := range terms {
+    term = strings.ToLower(strings.TrimSpace(term))
+    if term == "" || seen[term] {
+      continue
+    }
+    seen[term] = true
+    aliases = append(aliases, Alias{Term: term, Canonical: canonical})
+  }
+  return
+}
+
+// Looks up the canonical term of each aliased term and the component terms
+// of each canonical term.
+type AliasMap struct {
+  canonical map[string]string
+  components map[string][]string
+}
+
+func (aliases Aliases) Map() AliasMap {
+  m := AliasMap {
+    canonical: map[string]string {},
+    components: map[string][]string {},
+  }
+  for _, alias := range aliases {
+    m.canonical[alias.Term] = alias.Canonical
+    m.components[alias.Canonical] = append(m.components[alias.Canonical], alias.Term)
+  }
+  for canonical, terms := range m.components {
+    sort.Strings(terms)
+    m.components[canonical] = append([]string{canonical}, terms...)
+  }
+  return m
+}
+
+// Returns the canonical term term is counted under, which is term itself
+// unless it is an alias.
+func (m AliasMap) Canonical(term string) string {
+  if canonical, ok := m.canonical[strings.ToLower(term)]; ok {
+    return canonical
+  }
+  return term
+}
+
+// Reports whether term is a canonical term with aliases.
+func (m AliasMap) Aliased(term string) bool {
+  _, ok := m.components[term]
+  return ok
+}
+
+// Returns the terms counted under the canonical term of term: the canonical
+// term followed by its aliases, or just term when it has none.
+func (m AliasMap) Components(term string) []string {
+  if components, ok := m.components[m.Canonical(term)]; ok {
+    return components
+  }
+  return []string{term}
+}
+
+// Checks that aliases can be added to the aliases already in the map
+// without making a chain such as #ai -> ai -> artificial intelligence, or a
+// cycle. A canonical term can't itself be an alias, nor an alias a
+// canonical term with aliases of its own; moving an alias from one
+// canonical term to another is fine.
+func (m AliasMap) Check(aliases Aliases) error {
+  for _, alias := range aliases {
+    if canonical, ok := m.canonical[alias.Canonical]; ok {
+      return fmt.Errorf("%s is an alias of %s, add the aliases to %s instead", alias.Canonical, canonical, canonical)
+    }
+    if m.Aliased(alias.Term) {
+      return fmt.Errorf("%s has aliases of its own, move them to %s before aliasing it", alias.Term, alias.Canonical)
+    }
+  }
+  return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+  "reflect"
+  "testing"
+  "time"
+)
+
+func TestAliasMap(t *testing.T) {
+  aliases, err := NewAliases(" AI ", []string {"#AI", "artificial intelligence", "", "ai", "#ai"})
+  if err != nil {
+    t.Fatalf("NewAliases: %v", err)
+  }
+  if len(aliases) != 2 {
+    t.Errorf("NewAliases = %v, want blanks, repeats and the canonical term skipped", aliases)
+  }
+  aliasMap := aliases.Map()
+
+  if got := aliasMap.Canonical("#AI"); got != "ai" {
+    t.Errorf("Canonical(#AI) = %q, want ai", got)
+  }
+  if got := aliasMap.Canonical("robot"); got != "robot" {
+    t.Errorf("Canonical(robot) = %q, want it unchanged", got)
+  }
+  if !aliasMap.Aliased("ai") || aliasMap.Aliased("#ai") {
+    t.Errorf("Aliased should hold for the canonical term only")
+  }
+  want := []string {"ai", "#ai", "artificial intelligence"}
+  if got := aliasMap.Components("#ai"); !reflect.DeepEqual(got, want) {
+    t.Errorf("Components(#ai) = %v, want %v", got, want)
+  }
+  if got := aliasMap.Components("robot"); !reflect.DeepEqual(got, []string {"robot"}) {
+    t.Errorf("Components(robot) = %v, want just robot", got)
+  }
+
+  if _, err := NewAliases(" ", []string {"x"}); err == nil {
+    t.Errorf("NewAliases accepted a blank canonical term")
+  }
+}
+
+func TestAliasMapCheckRejectsChains(t *testing.T) {
+  existing, _ := NewAliases("ai", []string {"#ai"})
+  aliasMap := existing.Map()
+
+  for _, test := range []struct {
+    canonical string
+    terms []string
+    ok bool
+  }{
+    {"ai", []string {"artificial intelligence"}, true},
+    // Moving an alias to another canonical term
+    {"artificial intelligence", []string {"#ai"}, true},
+    // The canonical term is an alias
+    {"#ai", []string {"machine intelligence"}, false},
+    // The alias has aliases of its own
+    {"artificial intelligence", []string {"ai"}, false},
+    // Either way round a cycle is a chain
+    {"#ai", []string {"ai"}, false},
+  } {
+    aliases, _ := NewAliases(test.canonical, test.terms)
+    if err := aliasMap.Check(aliases); (err == nil) != test.ok {
+      t.Errorf("Check(%s <- %v) = %v, want ok %v", test.canonical, test.terms, err, test.ok)
+    }
+  }
+}
+
+func aliasTestStore(t *testing.T) *MemoryStore {
+  store := newTestStore(t,
+    testPost("http://t/1", "nairobi", "twitter", testNow.Add(-30 * time.Minute), map[string]int {"ai": 2}),
+    testPost("http://t/2", "nairobi", "twitter", testNow.Add(-20 * time.Minute), map[string]int {"#ai": 3}),
+    testPost("http://t/3", "nairobi", "twitter", testNow.Add(-90 * time.Minute), map[string]int {"#ai": 1, "robot": 2}),
+  )
+  aliases, _ := NewAliases("ai", []string {"#ai"})
+  store.InsertAliases(aliases)
+  return store
+}
+
+func TestAliasesAggregateTrends(t *testing.T) {
+  store := aliasTestStore(t)
+  from := testNow.Add(-2 * time.Hour)
+
+  counts, err := WordCountRootCollection(store, "nairobi", "", testParam(from), testParam(testNow), 2, 10, UnigramsOnly, nil, "", "")
+  if err != nil {
+    t.Fatalf("WordCountRootCollection: %v", err)
+  }
+  var ai *WordCount
+  for i := range counts {
+    if counts[i].Term == "#ai" {
+      t.Errorf("an alias is ranked on its own: %+v", counts[i])
+    }
+    if counts[i].Term == "ai" {
+      ai = &counts[i]
+    }
+  }
+  if ai == nil {
+    t.Fatalf("no trend for the canonical term in %+v", counts)
+  }
+  if ai.Occurrences != 6 || !reflect.DeepEqual(ai.Series, []int {1, 2}) {
+    t.Errorf("ai trend = %+v, want 6 occurrences over series [1 2]", *ai)
+  }
+  wantForms := TermForms {{Term: "#ai", Occurrences: 4}, {Term: "ai", Occurrences: 2}}
+  if !reflect.DeepEqual(ai.Forms, wantForms) {
+    t.Errorf("ai forms = %v, want %v", ai.Forms, wantForms)
+  }
+}
+
+func TestAliasesAggregateTermTrend(t *testing.T) {
+  store := aliasTestStore(t)
+  from := testNow.Add(-2 * time.Hour)
+
+  // Asking for an alias answers for its canonical term
+  termPackage, err := TrendsCollection(store, "", "nairobi", "#ai", testParam(from), testParam(testNow), 2, 1.0, 0.0, 0, 0, "", 0)
+  if err != nil {
+    t.Fatalf("TrendsCollection: %v", err)
+  }
+  if termPackage.Term != "ai" || !reflect.DeepEqual(termPackage.Series, []int {1, 5}) {
+    t.Errorf("trend = %s %v, want ai [1 5]", termPackage.Term, termPackage.Series)
+  }
+  if len(termPackage.Forms) != 2 {
+    t.Errorf("forms = %v, want both forms", termPackage.Forms)
+  }
+
+  // A term without aliases has no forms
+  termPackage, err = TrendsCollection(store, "", "nairobi", "robot", testParam(from), testParam(testNow), 2, 1.0, 0.0, 0, 0, "", 0)
+  if err != nil {
+    t.Fatalf("TrendsCollection: %v", err)
+  }
+  if termPackage.Term != "robot" || termPackage.Forms != nil {
+    t.Errorf("robot trend = %s with forms %v, want none", termPackage.Term, termPackage.Forms)
+  }
+}
@@ -0,0 +1,11 @@
+package main
+
+// Two terms used together, Term before Other alphabetically, and the
+// number of posts using both.
+type CoOccurrence struct {
+  Term string `json:"term"`
+  Other string `json:"other"`
+  Posts int `json:"posts"`
+}
+
+type CoOccurrences []CoOccurrence
\ No newline at end of file
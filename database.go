@@ -6,10 +6,11 @@ import (
     "fmt"
     "strings"
     //"regexp"
-    _ "github.com/lib/pq"
+    "github.com/lib/pq"
     "time"
-    "bytes"
     "os"
+    "strconv"
+    "bytes"
     "hash/fnv"
 )
 
@@ -19,33 +20,6 @@ const (
     DB_NAME     = "udadisi"
 )
 
-const (
-    Posts = iota
-    Terms
-    MinersTable
-)
-
-var tables = map[int]string{
-    0: "Posts",
-    1: "Terms",
-    2: "MinersTable",
-}
-
-var datetime = time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
-
-// Post: uid serial mined:datetime posted:datetime sourceURI: string
-var CREATE = map[int]string{
-    Posts: "CREATE TABLE IF NOT EXISTS posts(uid serial NOT NULL, mined timestamp without time zone, posted timestamp without time zone, sourceURI text, location text, source text, locationhash bigint)",
-    Terms: "CREATE TABLE IF NOT EXISTS terms(uid serial NOT NULL, postid integer, term text,  wordcount integer, posted timestamp without time zone, location text, locationhash bigint)",
-    MinersTable: "CREATE TABLE IF NOT EXISTS miners(uid serial NOT NULL, name text, source text, location text, url text, geocoord point, locationhash bigint)",
-}
-
-var DROP = map[int]string{
-    Posts: "DROP TABLE IF EXISTS posts",
-    Terms: "DROP TABLE IF EXISTS terms",
-    MinersTable: "DROP TABLE IF EXISTS miners",
-}
-
 // A DatabaseError indicates an error with the database
 type DatabaseError struct {
     Error error  // The raw error that precipitated this error, if any.
@@ -56,27 +30,25 @@ func (e *DatabaseError) String() string {
     return fmt.Sprintf("%s", e.Error)
 }
 
+// PostgresStore is the Store backed by the udadisi Postgres database.
+type PostgresStore struct {
+    db *sqlx.DB
+}
+
+func NewPostgresStore(db *sqlx.DB) *PostgresStore {
+    db.SetMaxOpenConns(20) //tune this
+    return &PostgresStore{db: db}
+}
+
 func LocationHash(s string) uint32 {
     h := fnv.New32a()
     h.Write([]byte(s))
     return h.Sum32()
 }
 
-func BuildDatabase() {
-
-    DropTable(DROP[Posts])
-    DropTable(DROP[Terms])
-    DropTable(DROP[MinersTable])
-    CreateTable(CREATE[Posts])
-    CreateTable(CREATE[Terms])
-    CreateTable(CREATE[MinersTable])
-    CreateIndexes()
-    AddStopwords()
-}
-
-func AddStopwords() (err error){
-    sql := "ALTER TABLE miners ADD stopwords varchar(255) DEFAULT '';"
-    
+// Returns the current schema version, 0 for a database that has never been
+// migrated.
+func (s *PostgresStore) SchemaVersion() (version int, err error) {
     defer func() {
         if r := recover(); r != nil {
             var ok bool
@@ -87,55 +59,88 @@ func AddStopwords() (err error){
         }
     }()
 
-    fmt.Println("# Adding stopwords " + sql)
-
-    if _, err := db.Exec(sql); err != nil {
-        checkErr(err)
+    var exists bool
+    checkErr(s.db.QueryRow("SELECT to_regclass('schema_version') IS NOT NULL").Scan(&exists))
+    if !exists {
+        return
     }
 
+    checkErr(s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version))
     return
 }
 
+// Applies the migrations between the current schema version and target,
+// each in its own transaction alongside its schema_version bookkeeping.
+func (s *PostgresStore) MigrateTo(target int) (err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    _, err = s.db.Exec("CREATE TABLE IF NOT EXISTS schema_version(version integer PRIMARY KEY, name text, applied timestamp without time zone DEFAULT now())")
+    checkErr(err)
 
-func CreateIndexes() {
-    CreateIndex("CREATE INDEX ON posts (uid);")
-    CreateIndex("CREATE INDEX ON terms (uid);")
-    CreateIndex("CREATE INDEX ON miners (uid);")
-    CreateIndex("CREATE INDEX ON posts (locationhash);")
-    CreateIndex("CREATE INDEX ON terms (locationhash);")
-    CreateIndex("CREATE INDEX ON miners (locationhash);")
-}
+    current, err := s.SchemaVersion()
+    checkErr(err)
 
-func ResetMinersDatabase() {
-    DropTable(DROP[MinersTable])
-    CreateTable(CREATE[MinersTable])
-}
+    steps, err := MigrationsBetween(current, target)
+    checkErr(err)
 
-func ClearData() (err error) {
+    for _, migration := range steps {
+        up := migration.Version > current
+        direction := "up"
+        statements := migration.Up
+        if !up {
+            direction = "down"
+            statements = migration.Down
+        }
 
-    if err := DropTable(DROP[Posts]); err != nil {
-        checkErr(err)
-    }
-    if err := DropTable(DROP[Terms]); err != nil {
-        checkErr(err)
-    }
-    if err := CreateTable(CREATE[Posts]); err != nil {
+        fmt.Println("# Migrating", direction, migration.Version, migration.Name)
+
+        tx, err := s.db.Begin()
         checkErr(err)
+        for _, statement := range statements {
+            if _, err := tx.Exec(statement); err != nil {
+                tx.Rollback()
+                checkErr(fmt.Errorf("migration %d (%s): %v", migration.Version, migration.Name, err))
+            }
+        }
+        if up {
+            _, err = tx.Exec("INSERT INTO schema_version (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+        } else {
+            _, err = tx.Exec("DELETE FROM schema_version WHERE version = $1", migration.Version)
+        }
+        if err != nil {
+            tx.Rollback()
+            checkErr(err)
+        }
+        checkErr(tx.Commit())
     }
-    if err := CreateTable(CREATE[Terms]); err != nil {
-        checkErr(err)
+
+    return
+}
+
+func (s *PostgresStore) ResetMiners() (err error) {
+    _, err = s.db.Exec("TRUNCATE miners RESTART IDENTITY")
+    if err == nil {
+        _, err = s.db.Exec("DELETE FROM stopwords WHERE scope = $1", StopwordMiner)
     }
+    return
+}
 
+// Removes all posts, terms and rollups, leaving the miners and the schema intact.
+func (s *PostgresStore) ClearData() (err error) {
+    _, err = s.db.Exec("TRUNCATE posts, terms, term_rollups_hourly, term_rollups_daily RESTART IDENTITY")
     return
 }
 
-func CountWords(s string) map[string]int {
-  counts := make(map[string]int)
-  fields := strings.Fields(s)
-  for i := 0; i < len(fields); i++ {
-    counts[fields[i]]++
-  }
-  return counts
+func (s *PostgresStore) Close() error {
+    return s.db.Close()
 }
 
 func ConnectToDatabase() *sqlx.DB {
@@ -157,7 +162,7 @@ func ConnectToDatabase() *sqlx.DB {
     return db
 }
 
-func CreateIndex(sql string) (err error) {
+func (s *PostgresStore) InsertMiner(miner Miner) (lastInsertId int, err error) {
     defer func() {
         if r := recover(); r != nil {
             var ok bool
@@ -168,16 +173,108 @@ func CreateIndex(sql string) (err error) {
         }
     }()
 
-    fmt.Println("# Creating index " + sql)
+    tx, err := s.db.Begin()
+    checkErr(err)
+    defer tx.Rollback()
+
+    err = tx.QueryRow("INSERT INTO miners (name, location, geocoord, source, url, locationhash, key_hash) VALUES($1,$2,POINT($3,$4),$5,$6,$7,$8) returning uid;", miner.Name, miner.Location, miner.GeoCoord.LatitudeValue(), miner.GeoCoord.LongitudeValue(), miner.Source, miner.Url, LocationHash(miner.Location), miner.KeyHash).Scan(&lastInsertId)
+    checkErr(err)
+    setMinerStopwords(tx, lastInsertId, miner.Stopwords)
+    checkErr(tx.Commit())
+
+    return
+}
+
+// Replaces the miner scoped stopwords of a miner with those in list, a
+// comma separated list as entered in the miner form.
+func setMinerStopwords(tx *sql.Tx, uid int, list string) {
+    _, err := tx.Exec("DELETE FROM stopwords WHERE scope = $1 AND target = $2", StopwordMiner, strconv.Itoa(uid))
+    checkErr(err)
+    stopwords, err := NewStopwords(StopwordMiner, strconv.Itoa(uid), SplitStopwords(list))
+    checkErr(err)
+    insertStopwords(tx, stopwords)
+}
 
-    if _, err := db.Exec(sql); err != nil {
+// Inserts stopwords, skipping those already present, and returns how many
+// were added.
+func insertStopwords(tx *sql.Tx, stopwords Stopwords) (added int) {
+    for start := 0; start < len(stopwords); start += insertChunkSize {
+        end := start + insertChunkSize
+        if end > len(stopwords) {
+            end = len(stopwords)
+        }
+
+        args := []interface{} {}
+        for _, stopword := range stopwords[start:end] {
+            args = append(args, stopword.Word, stopword.Scope, stopword.Target)
+        }
+        res, err := tx.Exec("INSERT INTO stopwords (word, scope, target) VALUES " + valuesPlaceholders(end - start, 3) + " ON CONFLICT (word, scope, target) DO NOTHING", args...)
         checkErr(err)
+        affected, err := res.RowsAffected()
+        checkErr(err)
+        added += int(affected)
     }
+    return
+}
+
+func (s *PostgresStore) UpdateMiner(miner Miner) (affected int64, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    tx, err := s.db.Begin()
+    checkErr(err)
+    defer tx.Rollback()
+
+    res, err := tx.Exec("UPDATE miners SET name=$1, location=$2, geocoord=POINT($3,$4), source=$5, url=$6, locationhash=$7 WHERE uid = $8;", miner.Name, miner.Location, miner.GeoCoord.LatitudeValue(), miner.GeoCoord.LongitudeValue(), miner.Source, miner.Url, LocationHash(miner.Location), miner.Uid)
+    checkErr(err)
+    
+    affected, err = res.RowsAffected()
+    checkErr(err)
+    if affected > 0 {
+        setMinerStopwords(tx, miner.Uid, miner.Stopwords)
+    }
+    checkErr(tx.Commit())
 
     return
 }
 
-func CreateTable(sql string) (err error) {
+// Maximum number of rows written by one multi-row INSERT, keeping well
+// inside Postgres' limit on bind parameters.
+const insertChunkSize = 1000
+
+// Returns the VALUES placeholders for rows rows of columns columns,
+// "($1,$2),($3,$4)" for 2 rows of 2 columns.
+func valuesPlaceholders(rows int, columns int) string {
+    var buffer bytes.Buffer
+    for row := 0; row < rows; row++ {
+        if row > 0 {
+            buffer.WriteString(",")
+        }
+        buffer.WriteString("(")
+        for column := 0; column < columns; column++ {
+            if column > 0 {
+                buffer.WriteString(",")
+            }
+            fmt.Fprintf(&buffer, "$%d", row * columns + column + 1)
+        }
+        buffer.WriteString(")")
+    }
+    return buffer.String()
+}
+
+// Stores a batch of posts and their terms, and adds them to the rollups, in
+// a single transaction. Posts are written with multi-row inserts, relying on
+// the unique (sourceURI, locationhash) index to skip duplicates, and terms
+// are written with COPY. Returns the uid given to each post, or 0 where the
+// post's sourceURI has already been stored for its location.
+func (s *PostgresStore) InsertPosts(posts []PostWithTerms) (uids []int, err error) {
     defer func() {
         if r := recover(); r != nil {
             var ok bool
@@ -188,16 +285,74 @@ func CreateTable(sql string) (err error) {
         }
     }()
 
-    fmt.Println("# Creating table " + sql)
+    tx, err := s.db.Begin()
+    checkErr(err)
+    defer tx.Rollback()
+
+    type postKey struct {
+        sourceURI string
+        locationhash int64
+    }
 
-    if _, err := db.Exec(sql); err != nil {
+    inserted := map[postKey]int {}
+    for start := 0; start < len(posts); start += insertChunkSize {
+        end := start + insertChunkSize
+        if end > len(posts) {
+            end = len(posts)
+        }
+
+        args := []interface{} {}
+        for _, p := range posts[start:end] {
+            post := p.Post
+            args = append(args, post.Source, post.Location, post.Mined.Format(time.RFC3339), post.Posted.Format(time.RFC3339), post.SourceURI, LocationHash(post.Location), post.Lang)
+        }
+        rows, err := tx.Query("INSERT INTO posts (source, location, mined, posted, sourceURI, locationhash, lang) VALUES " + valuesPlaceholders(end - start, 7) + " ON CONFLICT (sourceURI, locationhash) DO NOTHING RETURNING uid, sourceURI, locationhash", args...)
         checkErr(err)
+        for rows.Next() {
+            var uid int
+            var key postKey
+            checkErr(rows.Scan(&uid, &key.sourceURI, &key.locationhash))
+            inserted[key] = uid
+        }
+        checkErr(rows.Err())
+        rows.Close()
+    }
+
+    copyTerms, err := tx.Prepare(pq.CopyIn("terms", "postid", "term", "wordcount", "ngram", "posted", "location", "locationhash"))
+    checkErr(err)
+
+    stored := []PostWithTerms {}
+    uids = make([]int, len(posts))
+    for i, p := range posts {
+        post := p.Post
+        key := postKey{post.SourceURI, int64(LocationHash(post.Location))}
+        uid, ok := inserted[key]
+        if !ok {
+            fmt.Println("We already have", post.SourceURI, "for", post.Location)
+            continue
+        }
+        // Only the first of any repeats within the batch is stored
+        delete(inserted, key)
+
+        uids[i] = uid
+        post.Uid = uid
+        stored = append(stored, PostWithTerms{Post: post, Terms: p.Terms})
+        for _, term := range p.Terms {
+            _, err := copyTerms.Exec(uid, strings.ToLower(term.Term), term.WordCount, NgramOf(term.Term), post.Posted.Format(time.RFC3339), post.Location, int64(LocationHash(post.Location)))
+            checkErr(err)
+        }
     }
+    _, err = copyTerms.Exec()
+    checkErr(err)
+    checkErr(copyTerms.Close())
 
+    rollupPosts(tx, stored)
+
+    checkErr(tx.Commit())
     return
 }
 
-func DropTable(sql string) (err error) {
+func (s *PostgresStore) PostsCount(location string) (count int, err error) {
     defer func() {
         if r := recover(); r != nil {
             var ok bool
@@ -208,16 +363,73 @@ func DropTable(sql string) (err error) {
         }
     }()
 
-    fmt.Println("# Dropping table " + sql)
-    db := ConnectToDatabase()
-    if _, err := db.Exec(sql); err != nil {
+    if location != "all" {
+        locationhash := LocationHash(location)
+        errDb := s.db.QueryRow("SELECT count(uid) as count FROM posts where locationhash=$1", locationhash).Scan(&count)
+        checkErr(errDb)
+    } else {
+        errDb := s.db.QueryRow("SELECT count(uid) as count FROM posts").Scan(&count)
+        checkErr(errDb)    }
+    return
+}
+
+func (s *PostgresStore) LastMined(location string) (mined time.Time, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    if location != "all" {
+        locationhash := LocationHash(location)
+        errDb := s.db.QueryRow("SELECT mined FROM posts where locationhash=$1 ORDER BY mined DESC LIMIT 1", locationhash).Scan(&mined)
+        checkErr(errDb)
+    } else {
+        errDb := s.db.QueryRow("SELECT mined FROM posts ORDER BY mined DESC LIMIT 1").Scan(&mined)
+        checkErr(errDb)
+    }
+    return
+}
+
+const minerColumns = "uid, name, source, location, url, geocoord, COALESCE((SELECT string_agg(word, ', ' ORDER BY word) FROM stopwords WHERE scope = 'miner' AND target = miners.uid::text), ''), key_hash"
+
+func scanMiners(rows *sql.Rows) (miners Miners) {
+    defer rows.Close()
+    miners = Miners {}
+    for rows.Next() {
+        var miner Miner
+        err := rows.Scan(&miner.Uid, &miner.Name, &miner.Source, &miner.Location, &miner.Url, &miner.GeoCoord, &miner.Stopwords, &miner.KeyHash)
         checkErr(err)
+        miners = append(miners, miner)
     }
+    checkErr(rows.Err())
+    return
+}
 
+func (s *PostgresStore) Miners() (miners Miners, err error) {
+
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    rows, errDb := s.db.Query("SELECT " + minerColumns + " FROM miners ORDER BY uid")
+    checkErr(errDb)
+    miners = scanMiners(rows)
     return
 }
 
-func InsertMiner(name string, location string, latitude string, longitude string, source string, url string, stopwords string) (lastInsertId int, err error) {
+// Returns the miner with the given uid, or sql.ErrNoRows if there isn't one.
+func (s *PostgresStore) Miner(minerId int) (miner Miner, err error) {
     defer func() {
         if r := recover(); r != nil {
             var ok bool
@@ -228,22 +440,107 @@ func InsertMiner(name string, location string, latitude string, longitude string
         }
     }()
 
-    if latitude == "" {
-        latitude = "0"
+    rows, errDb := s.db.Query("SELECT " + minerColumns + " FROM miners WHERE uid=$1", minerId)
+    checkErr(errDb)
+    miners := scanMiners(rows)
+    if len(miners) == 0 {
+        return miner, sql.ErrNoRows
     }
-    if longitude == "" {
-        longitude = "0"
+    miner = miners[0]
+    return
+}
+
+func (s *PostgresStore) StopwordsFor(location string, source string) (stopwords []string, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    if location == "all" {
+        location = ""
+    }
+    if source == "all" {
+        source = ""
     }
 
-    fmt.Println(LocationHash(location))
+    rows, errDb := s.db.Query(`SELECT DISTINCT word FROM stopwords
+        WHERE scope = 'global'
+            OR (scope = 'location' AND (target = $1 OR $1 = ''))
+            OR (scope = 'source' AND (LOWER(target) = LOWER($2) OR $2 = ''))
+            OR (scope = 'miner' AND target IN (SELECT uid::text FROM miners WHERE (locationhash = $3 OR $1 = '') AND (source = $2 OR $2 = '')))`,
+        location, source, LocationHash(location))
+    checkErr(errDb)
+    defer rows.Close()
 
-    err = db.QueryRow("INSERT INTO miners (name, location, geocoord, source, url, locationhash, stopwords) VALUES($1,$2,POINT($3,$4),$5,$6,$7,$8) returning uid;", name, location, latitude, longitude, source, url, LocationHash(location), stopwords).Scan(&lastInsertId)
+    stopwords = []string{}
+    for rows.Next() {
+        var word string
+        checkErr(rows.Scan(&word))
+        stopwords = append(stopwords, word)
+    }
+    checkErr(rows.Err())
+    return
+}
+
+func (s *PostgresStore) Stopwords(scope string, target string) (stopwords Stopwords, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    rows, errDb := s.db.Query("SELECT uid, word, scope, target FROM stopwords WHERE (scope = $1 OR $1 = '') AND (target = $2 OR $2 = '') ORDER BY scope, target, word", scope, target)
+    checkErr(errDb)
+    defer rows.Close()
+
+    stopwords = Stopwords {}
+    for rows.Next() {
+        var stopword Stopword
+        checkErr(rows.Scan(&stopword.Uid, &stopword.Word, &stopword.Scope, &stopword.Target))
+        stopwords = append(stopwords, stopword)
+    }
+    checkErr(rows.Err())
+    return
+}
+
+func (s *PostgresStore) InsertStopwords(stopwords Stopwords) (added int, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    tx, err := s.db.Begin()
     checkErr(err)
+    defer tx.Rollback()
 
+    added = insertStopwords(tx, stopwords)
+    checkErr(tx.Commit())
     return
 }
 
-func UpdateMiner(name string, location string, latitude string, longitude string, source string, url string, stopwords string, uid int) (affected int64, err error) {
+func (s *PostgresStore) DeleteStopword(uid int) (affected int64, err error) {
+    res, err := s.db.Exec("DELETE FROM stopwords WHERE uid = $1", uid)
+    if err != nil {
+        return
+    }
+    return res.RowsAffected()
+}
+
+func (s *PostgresStore) Aliases() (aliases Aliases, err error) {
     defer func() {
         if r := recover(); r != nil {
             var ok bool
@@ -254,48 +551,94 @@ func UpdateMiner(name string, location string, latitude string, longitude string
         }
     }()
 
-    if latitude == "" { latitude = "0" }
-    if longitude == "" { longitude = "0" }
+    rows, errDb := s.db.Query("SELECT uid, term, canonical FROM aliases ORDER BY canonical, term")
+    checkErr(errDb)
+    defer rows.Close()
 
-    stmt, err := db.Prepare("UPDATE miners SET name=$1, location=$2, geocoord=POINT($3,$4), source=$5, url=$6, locationhash=$7, stopwords=$8 WHERE uid = $9;")
-    checkErr(err)
-    
-    res, err := stmt.Exec(name, location, latitude, longitude, source, url, LocationHash(location), stopwords, uid)
-    checkErr(err)
-    
-    affected, err = res.RowsAffected()
+    aliases = Aliases {}
+    for rows.Next() {
+        var alias Alias
+        checkErr(rows.Scan(&alias.Uid, &alias.Term, &alias.Canonical))
+        aliases = append(aliases, alias)
+    }
+    checkErr(rows.Err())
+    return
+}
+
+func (s *PostgresStore) InsertAliases(aliases Aliases) (added int, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    tx, err := s.db.Begin()
     checkErr(err)
+    defer tx.Rollback()
 
-    //err := db.QueryRow("UPDATE miners SET name=$1, location=$2, geocoord=POINT($3,$4), source=$5, url=$6, locationhash=$7, stopwords=$8 WHERE uid = $9;", name, location, latitude, longitude, source, url, LocationHash(location), stopwords, uid).Scan(&lastUpdateId)
-    //checkErr(err)
+    for start := 0; start < len(aliases); start += insertChunkSize {
+        end := start + insertChunkSize
+        if end > len(aliases) {
+            end = len(aliases)
+        }
 
+        args := []interface{} {}
+        for _, alias := range aliases[start:end] {
+            args = append(args, alias.Term, alias.Canonical)
+        }
+        res, err := tx.Exec("INSERT INTO aliases (term, canonical) VALUES " + valuesPlaceholders(end - start, 2) + " ON CONFLICT (term) DO UPDATE SET canonical = EXCLUDED.canonical WHERE aliases.canonical <> EXCLUDED.canonical", args...)
+        checkErr(err)
+        affected, err := res.RowsAffected()
+        checkErr(err)
+        added += int(affected)
+    }
+    checkErr(tx.Commit())
     return
 }
 
-func InsertTerm(location string, term string, wordcount int, postid int, posted time.Time) {
-    var lastInsertId int
-    err := db.QueryRow("INSERT INTO terms (postid, term, wordcount, posted, location, locationhash) VALUES($1,$2,$3,$4,$5,$6) returning uid;", postid, strings.ToLower(term), wordcount, posted.Format(time.RFC3339), location,LocationHash(location)).Scan(&lastInsertId)
-    checkErr(err)
+func (s *PostgresStore) InsertBatch(batch BatchStatus) (err error) {
+    _, err = s.db.Exec("INSERT INTO batches (id, minerid, status, received, completed, posts, accepted, duplicate, failed, rejected, error) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)",
+        batch.BatchId, batch.MinerId, batch.Status, batch.Received, batch.Completed, batch.Posts, batch.Accepted, batch.Duplicate, batch.Failed, batch.Rejected, batch.Error)
+    return
 }
 
-func InsertPost(source string, location string, sourceURI string, postedAt time.Time, minedAt time.Time) int {
-    lastInsertId := 0
+func (s *PostgresStore) UpdateBatch(batch BatchStatus) (err error) {
+    _, err = s.db.Exec("UPDATE batches SET status=$2, completed=$3, accepted=$4, duplicate=$5, failed=$6, error=$7 WHERE id=$1",
+        batch.BatchId, batch.Status, batch.Completed, batch.Accepted, batch.Duplicate, batch.Failed, batch.Error)
+    return
+}
 
-    // Check to see if we already have an entry for the sourceURI
-    duplicate := false
-    db.QueryRow("SELECT 1 FROM posts WHERE sourceURI=$1 AND locationhash=$2", sourceURI, LocationHash(location)).Scan(&duplicate)
+// Returns the batch with the given id, or sql.ErrNoRows if there isn't one.
+func (s *PostgresStore) Batch(id string) (batch BatchStatus, err error) {
+    err = s.db.QueryRow("SELECT id, minerid, status, received, completed, posts, accepted, duplicate, failed, rejected, error FROM batches WHERE id=$1", id).Scan(&batch.BatchId, &batch.MinerId, &batch.Status, &batch.Received, &batch.Completed, &batch.Posts, &batch.Accepted, &batch.Duplicate, &batch.Failed, &batch.Rejected, &batch.Error)
+    return
+}
 
-    if duplicate == false {
-       err := db.QueryRow("INSERT INTO posts (source, location, mined, posted, sourceURI, locationhash) VALUES($1,$2,$3,$4,$5,$6) returning uid;", source, location, minedAt.Format(time.RFC3339), postedAt.Format(time.RFC3339), sourceURI,LocationHash(location)).Scan(&lastInsertId)
-        checkErr(err)
-    } else {
-        fmt.Println("We already have", sourceURI, "for", location)
+func (s *PostgresStore) DeleteBatches(receivedBefore time.Time) (affected int64, err error) {
+    res, err := s.db.Exec("DELETE FROM batches WHERE received < $1", receivedBefore)
+    if err != nil {
+        return
     }
+    return res.RowsAffected()
+}
 
-    return lastInsertId
+func (s *PostgresStore) DeleteAlias(uid int) (affected int64, err error) {
+    res, err := s.db.Exec("DELETE FROM aliases WHERE uid = $1", uid)
+    if err != nil {
+        return
+    }
+    return res.RowsAffected()
 }
 
-func DatabasePostsCount(location string) (count int, err error) {
+// Splits the window between from and to into interval equal buckets and
+// returns, for each term, its total word count and number of posts in each
+// bucket. The bucketing and grouping are done by Postgres so only the
+// aggregated rows come back.
+func (s *PostgresStore) TermBuckets(source string, location string, fromTime time.Time, toTime time.Time, interval int, ngram NgramRange, lang string) (buckets TermBuckets, err error) {
     defer func() {
         if r := recover(); r != nil {
             var ok bool
@@ -306,17 +649,62 @@ func DatabasePostsCount(location string) (count int, err error) {
         }
     }()
 
-    if location != "all" {
-        locationhash := LocationHash(location)
-        errDb := db.QueryRow("SELECT count(uid) as count FROM posts where locationhash=$1", locationhash).Scan(&count)
-        checkErr(errDb)
-    } else {
-        errDb := db.QueryRow("SELECT count(uid) as count FROM posts").Scan(&count)
-        checkErr(errDb)    }
+    width := toTime.Sub(fromTime).Seconds() / float64(interval)
+
+    rows, errDb := s.db.Query(`SELECT terms.term,
+            LEAST(FLOOR(EXTRACT(EPOCH FROM (terms.posted - $1::timestamptz)) / $3)::integer, $4 - 1) AS bucket,
+            SUM(terms.wordcount), COUNT(*)
+        FROM terms JOIN posts ON terms.postid = posts.uid
+        WHERE terms.posted >= $1::timestamptz AND terms.posted < $2::timestamptz
+            AND (terms.locationhash = $5 OR $6 = '')
+            AND (LOWER(posts.source) = LOWER($7) OR $7 = '')
+            AND ` + ngramCondition("terms.ngram", 8) + `
+            AND (posts.lang = $10 OR $10 = '')
+        GROUP BY 1, 2`,
+        fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), width, interval, LocationHash(location), location, source, ngram.Min, ngram.Max, lang)
+    checkErr(errDb)
+    defer rows.Close()
+
+    buckets = TermBuckets {}
+    for rows.Next() {
+        var bucket TermBucket
+        checkErr(rows.Scan(&bucket.Term, &bucket.Bucket, &bucket.Occurrences, &bucket.Mentions))
+        buckets = append(buckets, bucket)
+    }
+    checkErr(rows.Err())
     return
 }
 
-func DatabaseLastMined(location string) (mined time.Time, err error) {
+// Selects rows whose ngram column is within an NgramRange passed as
+// $minParam (Min) and the parameter after it (Max). See NgramRange.Contains.
+func ngramCondition(column string, minParam int) string {
+    min := fmt.Sprintf("$%d", minParam)
+    max := fmt.Sprintf("$%d", minParam + 1)
+    return fmt.Sprintf("(%s >= %s AND (%s <= %s OR %s = %d) OR %s = 0)", column, min, column, max, max, MaxNgram, max)
+}
+
+// Selects the terms rows equal to any of a list of terms (see lowerTerms)
+// within a window for a location and source. Takes $1 from, $2 to, $3
+// terms, $4 locationhash, $5 location and $6 source. Terms are compared
+// exactly, as FirstSeen does, so a _ or % in a term or alias is not a
+// wildcard.
+const matchingTermsCondition = `terms.term = ANY($3)
+            AND terms.posted >= $1::timestamptz AND terms.posted < $2::timestamptz
+            AND (terms.locationhash = $4 OR $5 = '')
+            AND (LOWER(posts.source) = LOWER($6) OR $6 = '')`
+
+// Lowercases terms to match the stored terms and passes them as an array
+// parameter.
+func lowerTerms(terms []string) interface{} {
+    lowered := make([]string, len(terms))
+    for i, term := range terms {
+        lowered[i] = strings.ToLower(term)
+    }
+    return pq.Array(lowered)
+}
+
+// Returns the bucketed word counts of each of terms per source.
+func (s *PostgresStore) TermSourceBuckets(source string, location string, terms []string, fromTime time.Time, toTime time.Time, interval int) (buckets TermBuckets, err error) {
     defer func() {
         if r := recover(); r != nil {
             var ok bool
@@ -327,19 +715,31 @@ func DatabaseLastMined(location string) (mined time.Time, err error) {
         }
     }()
 
-    if location != "all" {
-        locationhash := LocationHash(location)
-        errDb := db.QueryRow("SELECT mined FROM posts where locationhash=$1 ORDER BY mined DESC LIMIT 1", locationhash).Scan(&mined)
-        checkErr(errDb)
-    } else {
-        errDb := db.QueryRow("SELECT mined FROM posts ORDER BY mined DESC LIMIT 1").Scan(&mined)
-        checkErr(errDb)
+    width := toTime.Sub(fromTime).Seconds() / float64(interval)
+
+    rows, errDb := s.db.Query(`SELECT terms.term, posts.source,
+            LEAST(FLOOR(EXTRACT(EPOCH FROM (terms.posted - $1::timestamptz)) / $7)::integer, $8 - 1) AS bucket,
+            SUM(terms.wordcount), COUNT(*)
+        FROM terms JOIN posts ON terms.postid = posts.uid
+        WHERE ` + matchingTermsCondition + `
+        GROUP BY 1, 2, 3`,
+        fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), lowerTerms(terms), LocationHash(location), location, source, width, interval)
+    checkErr(errDb)
+    defer rows.Close()
+
+    buckets = TermBuckets {}
+    for rows.Next() {
+        var bucket TermBucket
+        checkErr(rows.Scan(&bucket.Term, &bucket.Source, &bucket.Bucket, &bucket.Occurrences, &bucket.Mentions))
+        buckets = append(buckets, bucket)
     }
+    checkErr(rows.Err())
     return
 }
 
-func QueryMiners() (rows *sql.Rows, err error) {
-
+// Returns the most recent limit distinct posts that use any of terms,
+// oldest first, each with the terms it used.
+func (s *PostgresStore) TermSources(source string, location string, terms []string, fromTime time.Time, toTime time.Time, limit int) (posts Posts, err error) {
     defer func() {
         if r := recover(); r != nil {
             var ok bool
@@ -350,42 +750,135 @@ func QueryMiners() (rows *sql.Rows, err error) {
         }
     }()
 
-    rows, errDb := db.Query("SELECT * FROM miners")
+    rows, errDb := s.db.Query(`SELECT uid, mined, posted, sourceURI, location, source, lang,
+            (SELECT array_agg(DISTINCT used.term ORDER BY used.term) FROM terms used WHERE used.postid = matched.uid AND used.term = ANY($3))
+        FROM (
+            SELECT DISTINCT ON (posts.sourceURI) posts.uid, posts.mined, posts.posted, posts.sourceURI, posts.location, posts.source, posts.lang
+            FROM terms JOIN posts ON terms.postid = posts.uid
+            WHERE ` + matchingTermsCondition + `
+            ORDER BY posts.sourceURI, posts.posted
+        ) matched ORDER BY posted DESC LIMIT $7`,
+        fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), lowerTerms(terms), LocationHash(location), location, source, limit)
     checkErr(errDb)
+    defer rows.Close()
+
+    posts = Posts {}
+    for rows.Next() {
+        var post Post
+        checkErr(rows.Scan(&post.Uid, &post.Mined, &post.Posted, &post.SourceURI, &post.Location, &post.Source, &post.Lang, pq.Array(&post.Matched)))
+        posts = append(posts, post)
+    }
+    checkErr(rows.Err())
+
+    // Most recent were selected first, hand them back in posted order
+    for i, j := 0, len(posts) - 1; i < j; i, j = i + 1, j - 1 {
+        posts[i], posts[j] = posts[j], posts[i]
+    }
     return
 }
 
-func QueryMinerForId(minerId int) *sql.Rows {
-    rows, err := db.Query("SELECT * FROM miners WHERE uid=$1", minerId)
-    checkErr(err)
+// Returns the terms that co-occur with any of terms in at least minSupport
+// posts, excluding terms themselves, the limit that co-occur most first.
+func (s *PostgresStore) RelatedTerms(source string, location string, terms []string, fromTime time.Time, toTime time.Time, minSupport int, limit int) (related []Related, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
 
-    return rows
-}
+    rows, errDb := s.db.Query(`WITH related AS (
+            SELECT related.term, SUM(related.wordcount) AS occurrences, COUNT(DISTINCT related.postid) AS mentions
+            FROM terms related
+            WHERE related.postid IN (
+                    SELECT terms.postid FROM terms JOIN posts ON terms.postid = posts.uid
+                    WHERE ` + matchingTermsCondition + `)
+                AND related.term <> ALL($3)
+            GROUP BY related.term
+            HAVING COUNT(DISTINCT related.postid) >= $7
+            ORDER BY occurrences DESC, related.term
+            LIMIT NULLIF($8, 0)
+        ), background AS (
+            SELECT terms.term, COUNT(DISTINCT terms.postid) AS posts
+            FROM terms JOIN posts ON terms.postid = posts.uid
+            WHERE terms.term IN (SELECT term FROM related)
+                AND terms.posted >= $1::timestamptz AND terms.posted < $2::timestamptz
+                AND (terms.locationhash = $4 OR $5 = '')
+                AND (LOWER(posts.source) = LOWER($6) OR $6 = '')
+            GROUP BY terms.term
+        )
+        SELECT related.term, related.occurrences, related.mentions, background.posts
+        FROM related JOIN background ON related.term = background.term
+        ORDER BY related.occurrences DESC, related.term`,
+        fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), lowerTerms(terms), LocationHash(location), location, source, minSupport, limit)
+    checkErr(errDb)
+    defer rows.Close()
 
-func QueryStopwordsFor(location string, source string) (rows *sql.Rows, err error) {
-    
-    locationCondition := ""
-    if (location == "all") || (location == "") { 
-        locationCondition =  "locationhash > 0"
-    } else {
-        lh := LocationHash(location)
-        locationhash := fmt.Sprint(lh)
-        locationCondition =  "locationhash = " + locationhash
+    related = []Related {}
+    for rows.Next() {
+        var r Related
+        checkErr(rows.Scan(&r.Term, &r.Occurrences, &r.Mentions, &r.Background))
+        related = append(related, r)
     }
+    checkErr(rows.Err())
+    return
+}
 
-    sourceCondition := "source = '" + source + "'"
-    if (source == "all") || (source == "") { 
-        sourceCondition = "source LIKE '%'"
+func (s *PostgresStore) CountPosts(source string, location string, terms []string, fromTime time.Time, toTime time.Time) (count int, err error) {
+    if len(terms) == 0 {
+        err = s.db.QueryRow(`SELECT COUNT(*) FROM posts
+            WHERE posted >= $1::timestamptz AND posted < $2::timestamptz
+                AND (locationhash = $3 OR $4 = '')
+                AND (LOWER(source) = LOWER($5) OR $5 = '')`,
+            fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), LocationHash(location), location, source).Scan(&count)
+        return
     }
+    err = s.db.QueryRow(`SELECT COUNT(DISTINCT terms.postid)
+        FROM terms JOIN posts ON terms.postid = posts.uid
+        WHERE ` + matchingTermsCondition,
+        fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), lowerTerms(terms), LocationHash(location), location, source).Scan(&count)
+    return
+}
 
-    statement := "SELECT stopwords FROM miners WHERE " + locationCondition + " AND " + sourceCondition + ";"
-    rows, err = db.Query(statement)
-    
-    checkErr(err);
+func (s *PostgresStore) PostBuckets(source string, location string, fromTime time.Time, toTime time.Time, interval int) (counts []int, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    width := toTime.Sub(fromTime).Seconds() / float64(interval)
+
+    rows, errDb := s.db.Query(`SELECT LEAST(FLOOR(EXTRACT(EPOCH FROM (posted - $1::timestamptz)) / $6)::integer, $7 - 1) AS bucket, COUNT(*)
+        FROM posts
+        WHERE posted >= $1::timestamptz AND posted < $2::timestamptz
+            AND (locationhash = $3 OR $4 = '')
+            AND (LOWER(source) = LOWER($5) OR $5 = '')
+        GROUP BY 1`,
+        fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), LocationHash(location), location, source, width, interval)
+    checkErr(errDb)
+    defer rows.Close()
+
+    counts = make([]int, interval)
+    for rows.Next() {
+        var bucket, count int
+        checkErr(rows.Scan(&bucket, &count))
+        counts[bucket] = count
+    }
+    checkErr(rows.Err())
     return
 }
 
-func QueryTerms(source string, location string, term string, fromDate string, toDate string) (rows *sql.Rows, err error) {
+// Returns each pair of terms used together in the same posts, the pairs
+// shared by the most posts first.
+func (s *PostgresStore) CoOccurrences(source string, location string, terms []string, fromTime time.Time, toTime time.Time) (pairs CoOccurrences, err error) {
     defer func() {
         if r := recover(); r != nil {
             var ok bool
@@ -396,96 +889,289 @@ func QueryTerms(source string, location string, term string, fromDate string, to
         }
     }()
 
-    fromTime, err := time.Parse("200601021504", fromDate)
-    if err != nil {
-        fmt.Errorf("invalid from date: %v", err)
+    rows, errDb := s.db.Query(`SELECT terms.term, other.term, COUNT(DISTINCT terms.postid) AS posts
+        FROM terms
+            JOIN posts ON terms.postid = posts.uid
+            JOIN terms other ON other.postid = terms.postid AND other.term > terms.term
+        WHERE ` + matchingTermsCondition + `
+            AND other.term = ANY($3)
+        GROUP BY terms.term, other.term
+        ORDER BY posts DESC, terms.term, other.term`,
+        fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), lowerTerms(terms), LocationHash(location), location, source)
+    checkErr(errDb)
+    defer rows.Close()
+
+    pairs = CoOccurrences {}
+    for rows.Next() {
+        var pair CoOccurrence
+        checkErr(rows.Scan(&pair.Term, &pair.Other, &pair.Posts))
+        pairs = append(pairs, pair)
     }
+    checkErr(rows.Err())
+    return
+}
 
-    toTime, err := time.Parse("200601021504", toDate)
-    if err != nil {
-        fmt.Errorf("invalid to date: %v", err)
+func (s *PostgresStore) FirstSeen(source string, location string, terms []string) (firstSeen map[string]time.Time, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    rows, errDb := s.db.Query(`SELECT terms.term, MIN(terms.posted)
+        FROM terms JOIN posts ON terms.postid = posts.uid
+        WHERE terms.term = ANY($1)
+            AND (terms.locationhash = $2 OR $3 = '')
+            AND (LOWER(posts.source) = LOWER($4) OR $4 = '')
+        GROUP BY 1`,
+        lowerTerms(terms), LocationHash(location), location, source)
+    checkErr(errDb)
+    defer rows.Close()
+
+    firstSeen = map[string]time.Time {}
+    for rows.Next() {
+        var term string
+        var posted time.Time
+        checkErr(rows.Scan(&term, &posted))
+        firstSeen[term] = posted
     }
+    checkErr(rows.Err())
+    return
+}
 
-    if location != "" {
-        locationhash := LocationHash(location)
+// Adds newly stored posts' terms to each of the rollups. The counts are
+// summed per rollup row first so each row is upserted once.
+func rollupPosts(tx *sql.Tx, posts []PostWithTerms) {
+    type rowKey struct {
+        bucket time.Time
+        term string
+        locationhash int64
+        source string
+        lang string
+    }
 
-        if term != "" {
-            rows, err = db.Query("SELECT terms.*, posts.source FROM terms, posts WHERE terms.postid=posts.uid AND posts.locationhash = $4 AND terms.posted between $1 AND $2 AND LOWER(term) LIKE LOWER($3) AND (LOWER(source) = LOWER($5) OR $5 = '') ORDER BY terms.posted, term", fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), term, locationhash, source)
-        } else {
-            rows, err = db.Query("SELECT terms.*, posts.source FROM terms, posts WHERE terms.postid=posts.uid AND posts.locationhash = $3 AND terms.posted between $1 AND $2 AND (LOWER(posts.source) = LOWER($4) OR $4 = '') ORDER BY terms.posted, term", fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), locationhash, source)
+    for _, rollup := range Rollups {
+        keys := []rowKey {}
+        locations := map[rowKey]string {}
+        counts := map[rowKey]*TermBucket {}
+        for _, p := range posts {
+            for _, term := range p.Terms {
+                key := rowKey{rollup.Truncate(p.Post.Posted), strings.ToLower(term.Term), int64(LocationHash(p.Post.Location)), p.Post.Source, p.Post.Lang}
+                if _, ok := counts[key]; !ok {
+                    keys = append(keys, key)
+                    locations[key] = p.Post.Location
+                    counts[key] = &TermBucket{}
+                }
+                counts[key].Occurrences += term.WordCount
+                counts[key].Mentions++
+            }
         }
-    } else {
-        if term != "" {
-            rows, err = db.Query("SELECT terms.*, posts.source FROM terms, posts WHERE terms.postid=posts.uid AND terms.posted between $1 AND $2 AND LOWER(term) LIKE LOWER($3) AND (LOWER(source) = LOWER($4) OR $4 = '') ORDER BY terms.posted, term", fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), term, source)
-        } else {
-            rows, err = db.Query("SELECT terms.*, posts.source FROM terms, posts WHERE terms.postid=posts.uid AND terms.posted between $1 AND $2 AND (LOWER(posts.source) = LOWER($3) OR $3 = '') ORDER BY terms.posted, term", fromTime.Format(time.RFC3339), toTime.Format(time.RFC3339), source)
+
+        for start := 0; start < len(keys); start += insertChunkSize {
+            end := start + insertChunkSize
+            if end > len(keys) {
+                end = len(keys)
+            }
+
+            args := []interface{} {}
+            for _, key := range keys[start:end] {
+                args = append(args, key.bucket.Format(time.RFC3339), key.term, NgramOf(key.term), key.locationhash, locations[key], key.source, key.lang, counts[key].Occurrences, counts[key].Mentions)
+            }
+            _, err := tx.Exec(`INSERT INTO ` + rollup.Table() + ` AS rollup (bucket, term, ngram, locationhash, location, source, lang, occurrences, mentions)
+                VALUES ` + valuesPlaceholders(end - start, 9) + `
+                ON CONFLICT (bucket, term, locationhash, source, lang) DO UPDATE
+                SET occurrences = rollup.occurrences + EXCLUDED.occurrences, mentions = rollup.mentions + EXCLUDED.mentions`, args...)
+            checkErr(err)
         }
     }
-    checkErr(err)
-    return
 }
 
-func QueryTermsForPost(postid int) *sql.Rows {
-    rows, err := db.Query("SELECT terms.*, posts.source FROM terms, posts WHERE terms.postid=posts.uid AND postid=$1", postid)
+// Rebuilds every rollup from the raw terms, for data stored before the
+// rollups existed.
+func (s *PostgresStore) BackfillRollups() (err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    tx, err := s.db.Begin()
     checkErr(err)
+    defer tx.Rollback()
 
-    return rows
+    for _, rollup := range Rollups {
+        fmt.Println("# Backfilling", rollup.Table())
+        _, err = tx.Exec("TRUNCATE " + rollup.Table())
+        checkErr(err)
+        _, err = tx.Exec(`INSERT INTO ` + rollup.Table() + ` (bucket, term, ngram, locationhash, location, source, lang, occurrences, mentions)
+            SELECT date_trunc($1, terms.posted AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', terms.term, MAX(terms.ngram), posts.locationhash, MIN(posts.location), COALESCE(posts.source, ''), posts.lang, SUM(terms.wordcount), COUNT(*)
+            FROM terms JOIN posts ON terms.postid = posts.uid
+            WHERE terms.term IS NOT NULL AND posts.locationhash IS NOT NULL
+            GROUP BY 1, 2, 4, 6, 7`, rollup.Precision())
+        checkErr(err)
+    }
+
+    checkErr(tx.Commit())
+    return
 }
 
-func QueryPosts(args ...string) *sql.Rows {
-    query := "SELECT * FROM posts"
-    var buffer = bytes.NewBufferString(query)
-    for _, v := range args {
-        buffer.WriteString(fmt.Sprint(v, " "))
+// Returns the same buckets as TermBuckets, read from a rollup. The window
+// must be aligned to the rollup, see RollupWindow.
+func (s *PostgresStore) RollupTermBuckets(rollup Rollup, source string, location string, fromTime time.Time, toTime time.Time, interval int, ngram NgramRange, lang string) (buckets TermBuckets, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    width := toTime.Sub(fromTime).Seconds() / float64(interval)
+
+    rows, errDb := s.db.Query(`SELECT term,
+            LEAST(FLOOR(EXTRACT(EPOCH FROM (bucket - $1::timestamptz)) / $3)::integer, $4 - 1) AS bucket,
+            SUM(occurrences), SUM(mentions)
+        FROM ` + rollup.Table() + `
+        WHERE bucket >= $1::timestamptz AND bucket < $2::timestamptz
+            AND (locationhash = $5 OR $6 = '')
+            AND (LOWER(source) = LOWER($7) OR $7 = '')
+            AND ` + ngramCondition("ngram", 8) + `
+            AND (lang = $10 OR $10 = '')
+        GROUP BY 1, 2`,
+        fromTime.UTC().Format(time.RFC3339), toTime.UTC().Format(time.RFC3339), width, interval, LocationHash(location), location, source, ngram.Min, ngram.Max, lang)
+    checkErr(errDb)
+    defer rows.Close()
+
+    buckets = TermBuckets {}
+    for rows.Next() {
+        var bucket TermBucket
+        checkErr(rows.Scan(&bucket.Term, &bucket.Bucket, &bucket.Occurrences, &bucket.Mentions))
+        buckets = append(buckets, bucket)
     }
-    query = buffer.String()
-    rows, err := db.Query(query)
-    checkErr(err)
+    checkErr(rows.Err())
+    return
+}
 
-    return rows
+// Returns the same buckets as TermSourceBuckets, read from a rollup.
+func (s *PostgresStore) RollupTermSourceBuckets(rollup Rollup, source string, location string, terms []string, fromTime time.Time, toTime time.Time, interval int) (buckets TermBuckets, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    width := toTime.Sub(fromTime).Seconds() / float64(interval)
+
+    rows, errDb := s.db.Query(`SELECT term, source,
+            LEAST(FLOOR(EXTRACT(EPOCH FROM (bucket - $1::timestamptz)) / $7)::integer, $8 - 1) AS bucket,
+            SUM(occurrences), SUM(mentions)
+        FROM ` + rollup.Table() + `
+        WHERE term = ANY($3)
+            AND bucket >= $1::timestamptz AND bucket < $2::timestamptz
+            AND (locationhash = $4 OR $5 = '')
+            AND (LOWER(source) = LOWER($6) OR $6 = '')
+        GROUP BY 1, 2, 3`,
+        fromTime.UTC().Format(time.RFC3339), toTime.UTC().Format(time.RFC3339), lowerTerms(terms), LocationHash(location), location, source, width, interval)
+    checkErr(errDb)
+    defer rows.Close()
+
+    buckets = TermBuckets {}
+    for rows.Next() {
+        var bucket TermBucket
+        checkErr(rows.Scan(&bucket.Term, &bucket.Source, &bucket.Bucket, &bucket.Occurrences, &bucket.Mentions))
+        buckets = append(buckets, bucket)
+    }
+    checkErr(rows.Err())
+    return
 }
 
-func QueryAll() {
-    rows, err := db.Query("select Posts.*, Terms.term from posts inner join terms on terms.postid=posts.uid")
-    checkErr(err)
+// Returns the bucketed word counts of terms per source in slices of the
+// raw terms, as used to move rollup buckets to a window not ending on the
+// hour. Each slice is looked up by its own range of terms.posted.
+func (s *PostgresStore) TermSliceBuckets(source string, location string, terms []string, fromTime time.Time, width time.Duration, slices int, length time.Duration, ngram NgramRange, lang string) (buckets TermBuckets, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    rows, errDb := s.db.Query(`SELECT terms.term, posts.source, slice, SUM(terms.wordcount), COUNT(*)
+        FROM generate_series(0, $3 - 1) AS slice
+            JOIN terms ON terms.posted >= $1::timestamptz + slice * $2 * INTERVAL '1 second'
+                AND terms.posted < $1::timestamptz + (slice * $2 + $4) * INTERVAL '1 second'
+            JOIN posts ON terms.postid = posts.uid
+        WHERE (cardinality($5::text[]) = 0 OR terms.term = ANY($5))
+            AND (terms.locationhash = $6 OR $7 = '')
+            AND (LOWER(posts.source) = LOWER($8) OR $8 = '')
+            AND ` + ngramCondition("terms.ngram", 9) + `
+            AND (posts.lang = $11 OR $11 = '')
+        GROUP BY 1, 2, 3`,
+        fromTime.Format(time.RFC3339), width.Seconds(), slices, length.Seconds(), lowerTerms(terms), LocationHash(location), location, source, ngram.Min, ngram.Max, lang)
+    checkErr(errDb)
+    defer rows.Close()
 
-    fmt.Println("uid | minded | posted | sourceURI | term")
+    buckets = TermBuckets {}
     for rows.Next() {
-        var uid int
-        var minded time.Time
-        var posted time.Time
-        var sourceURI string
-        var term string
-        err = rows.Scan(&uid, &minded, &posted, &sourceURI, &term)
-        checkErr(err)
-        fmt.Printf("%3v | %6v | %6v | %6v | %6v\n", uid, minded, posted, sourceURI, term)
+        var bucket TermBucket
+        checkErr(rows.Scan(&bucket.Term, &bucket.Source, &bucket.Bucket, &bucket.Occurrences, &bucket.Mentions))
+        buckets = append(buckets, bucket)
     }
+    checkErr(rows.Err())
+    return
 }
 
-func DeleteMiner(uid int) (affected int64, err error) {
-    stmt, err := db.Prepare("DELETE FROM miners where uid=$1")
+func (s *PostgresStore) DeleteMiner(uid int) (affected int64, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            err, ok = r.(error)
+            if !ok {
+                err = fmt.Errorf("Database: %v", r)
+            }
+        }
+    }()
+
+    stmt, err := s.db.Prepare("DELETE FROM miners where uid=$1")
     checkErr(err)
+    defer stmt.Close()
     
     res, err := stmt.Exec(uid)
     checkErr(err)
     
     affected, err = res.RowsAffected()
     checkErr(err)
+
+    _, err = s.db.Exec("DELETE FROM stopwords WHERE scope = $1 AND target = $2", StopwordMiner, strconv.Itoa(uid))
+    checkErr(err)
     
     return
 }
 
-func DeletePost(db *sqlx.DB, uid int) {
-    stmt, err := db.Prepare("delete from posts where uid=$1")
-    checkErr(err)
-
-    res, err := stmt.Exec(uid)
-    checkErr(err)
-
-    affect, err := res.RowsAffected()
-    checkErr(err)
-
-    fmt.Println(affect, "rows changed")
+// Replaces the hash of a miner's API key, invalidating its old key.
+func (s *PostgresStore) SetMinerKeyHash(uid int, keyHash string) (affected int64, err error) {
+    res, err := s.db.Exec("UPDATE miners SET key_hash=$1 WHERE uid=$2", keyHash, uid)
+    if err != nil {
+        return
+    }
+    return res.RowsAffected()
 }
 
 func checkErr(err error) {
@@ -493,4 +1179,4 @@ func checkErr(err error) {
         fmt.Println("Error:", &DatabaseError{err})
         panic(&DatabaseError{err})
     }
-}
\ No newline at end of file
+}
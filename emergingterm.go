@@ -0,0 +1,27 @@
+package main
+
+import (
+  "time"
+)
+
+// A term used significantly more in a window than in the baseline window
+// before it. Rates are occurrences per hour. New is set when the term was
+// first seen for the location and source within the window.
+type EmergingTerm struct {
+  Term string `json:"term"`
+  Ngram int `json:"ngram"`
+  Occurrences int `json:"occurrences"`
+  Mentions int `json:"mentions"`
+  BaselineOccurrences int `json:"baseline_occurrences"`
+  Rate float64 `json:"rate"`
+  BaselineRate float64 `json:"baseline_rate"`
+  // How significantly the term's share of all occurrences rose over the
+  // baseline, as a log-likelihood ratio
+  Novelty float64 `json:"novelty"`
+  New bool `json:"new"`
+  FirstSeen time.Time `json:"first_seen"`
+  // The terms counted under Term when it has aliases
+  Forms TermForms `json:"forms,omitempty"`
+}
+
+type EmergingTerms []EmergingTerm
\ No newline at end of file
@@ -0,0 +1,18 @@
+package main
+
+// An Engine serves the API, web and admin pages from its Store, and stores
+// the posts sent by miners through its Ingester, tokenizing any raw text
+// they send with its tokenizer config.
+type Engine struct {
+  store Store
+  ingester *Ingester
+  tokenizer TokenizerConfig
+}
+
+func NewEngine(store Store) *Engine {
+  return &Engine{
+    store: store,
+    ingester: NewIngester(store, IngestWorkers(), IngestQueueSize),
+    tokenizer: TokenizerConfigFromEnv(),
+  }
+}
\ No newline at end of file
@@ -0,0 +1,30 @@
+package main
+
+import (
+  "math"
+)
+
+// Scores the last interval by its deviation from an exponentially weighted
+// moving average of the trailing intervals, in units of the exponentially
+// weighted standard deviation. Alpha, between 0 and 1, is the weight given
+// to each newer interval; higher values forget the past faster.
+type EWMAScorer struct {
+  Alpha float64
+}
+
+func (scorer EWMAScorer) Score(series []int) Score {
+  current, baseline := splitSeries(series)
+  if len(baseline) == 0 {
+    return Score{Value: current / countDeviation(0, 0)}
+  }
+
+  mean := baseline[0]
+  variance := 0.0
+  for _, count := range baseline[1:] {
+    difference := count - mean
+    mean += scorer.Alpha * difference
+    variance = (1 - scorer.Alpha) * (variance + scorer.Alpha * difference * difference)
+  }
+
+  return Score{Value: (current - mean) / countDeviation(math.Sqrt(variance), mean), Baseline: mean}
+}
\ No newline at end of file
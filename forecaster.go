@@ -0,0 +1,65 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "sort"
+  "strings"
+)
+
+// A Forecaster fits a model to a series of counts and predicts the next
+// horizon values, each with the standard deviation of its error. Season is
+// the number of intervals in a seasonal cycle, such as 24 for hourly
+// intervals, or 0 for none.
+type Forecaster interface {
+  Forecast(series []float64, season int, horizon int) (predictions []float64, deviations []float64)
+}
+
+// The forecasters available to the model query parameter, by name. Add a
+// model by registering its Forecaster here.
+var Forecasters = map[string]Forecaster{
+  "holtwinters": HoltWintersForecaster{},
+  "linear": LinearForecaster{},
+}
+
+// The model used when none is asked for
+const DefaultForecastModel = "holtwinters"
+
+// Returns the forecaster for the model query parameter, or the default
+// forecaster for "".
+func ForecasterFor(model string) (Forecaster, error) {
+  if model == "" {
+    model = DefaultForecastModel
+  }
+  forecaster, ok := Forecasters[strings.ToLower(model)]
+  if !ok {
+    models := []string {}
+    for name := range Forecasters {
+      models = append(models, name)
+    }
+    sort.Strings(models)
+    return nil, fmt.Errorf("Unknown model %q, available: %s", model, strings.Join(models, ", "))
+  }
+  return forecaster, nil
+}
+
+// Returns how many standard deviations either side of a prediction hold
+// the given share of outcomes, such as 1.96 for 0.95, assuming normal
+// errors.
+func confidenceMultiplier(confidence float64) float64 {
+  return math.Sqrt2 * math.Erfinv(confidence)
+}
+
+// The standard deviation of a model's errors, from its one step ahead
+// residuals.
+func residualDeviation(residuals []float64, parameters int) float64 {
+  freedom := len(residuals) - parameters
+  if freedom < 1 {
+    freedom = 1
+  }
+  sum := 0.0
+  for _, residual := range residuals {
+    sum += residual * residual
+  }
+  return math.Sqrt(sum / float64(freedom))
+}
\ No newline at end of file
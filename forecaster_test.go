@@ -0,0 +1,86 @@
+package main
+
+import (
+  "math"
+  "testing"
+)
+
+func closeTo(a float64, b float64) bool {
+  return math.Abs(a - b) < 1e-6
+}
+
+func TestLinearForecaster(t *testing.T) {
+  // A straight line is continued with no error
+  series := []float64 {3, 5, 7, 9, 11, 13}
+  predictions, deviations := LinearForecaster{}.Forecast(series, 0, 3)
+  for h, want := range []float64 {15, 17, 19} {
+    if !closeTo(predictions[h], want) || !closeTo(deviations[h], 0) {
+      t.Errorf("step %d = %v ± %v, want %v ± 0", h + 1, predictions[h], deviations[h], want)
+    }
+  }
+
+  // As is a line with a repeating offset over two seasons
+  series = []float64 {}
+  offsets := []float64 {0, 4, 1}
+  for i := 0; i < 9; i++ {
+    series = append(series, float64(i) + offsets[i % 3])
+  }
+  predictions, _ = LinearForecaster{}.Forecast(series, 3, 3)
+  for h, want := range []float64 {9, 14, 12} {
+    if !closeTo(predictions[h], want) {
+      t.Errorf("seasonal step %d = %v, want %v", h + 1, predictions[h], want)
+    }
+  }
+}
+
+func TestHoltWintersForecaster(t *testing.T) {
+  series := []float64 {2, 4, 6, 8, 10, 12, 14, 16}
+  predictions, deviations := HoltWintersForecaster{}.Forecast(series, 0, 3)
+  for h, want := range []float64 {18, 20, 22} {
+    if !closeTo(predictions[h], want) {
+      t.Errorf("step %d = %v, want %v", h + 1, predictions[h], want)
+    }
+  }
+  for h := 1; h < len(deviations); h++ {
+    if deviations[h] < deviations[h - 1] {
+      t.Errorf("deviations %v shrink with the horizon", deviations)
+    }
+  }
+
+  // A season that repeats exactly is repeated
+  series = []float64 {}
+  for i := 0; i < 12; i++ {
+    series = append(series, []float64 {1, 5, 3, 9}[i % 4])
+  }
+  predictions, _ = HoltWintersForecaster{}.Forecast(series, 4, 4)
+  for h, want := range []float64 {1, 5, 3, 9} {
+    if !closeTo(predictions[h], want) {
+      t.Errorf("seasonal step %d = %v, want %v", h + 1, predictions[h], want)
+    }
+  }
+}
+
+func TestForecastersShortSeries(t *testing.T) {
+  for name, forecaster := range Forecasters {
+    predictions, deviations := forecaster.Forecast([]float64 {4}, 24, 2)
+    if len(predictions) != 2 || predictions[0] != 4 || predictions[1] != 4 || deviations[1] <= deviations[0] {
+      t.Errorf("%s on one value = %v ± %v, want it repeated with growing error", name, predictions, deviations)
+    }
+    predictions, _ = forecaster.Forecast(nil, 0, 1)
+    if len(predictions) != 1 || predictions[0] != 0 {
+      t.Errorf("%s on no values = %v, want 0", name, predictions)
+    }
+  }
+}
+
+func TestForecasterFor(t *testing.T) {
+  if forecaster, err := ForecasterFor(""); err != nil || forecaster != Forecasters[DefaultForecastModel] {
+    t.Errorf("ForecasterFor(\"\") = %v, %v, want the default model", forecaster, err)
+  }
+  if _, err := ForecasterFor("arima"); err == nil {
+    t.Errorf("ForecasterFor accepted an unknown model")
+  }
+  if got := confidenceMultiplier(0.95); math.Abs(got - 1.96) > 0.001 {
+    t.Errorf("confidenceMultiplier(0.95) = %v, want 1.96", got)
+  }
+}
@@ -6,7 +6,7 @@ import (
   "os"
 )
 
-func AdminIndex(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) AdminIndex(w http.ResponseWriter, r *http.Request) {
   sess, err := globalSessions.SessionStart(w, r)
   if err != nil {
       //need logging here instead of print
@@ -20,31 +20,22 @@ func AdminIndex(w http.ResponseWriter, r *http.Request) {
   } else {
     content := make(map[string]interface{})
     content["Title"] = "Admin Home Page"
-    renderTemplate(w, "admin/index", content)
+    e.renderAdminIndex(w, content)
   }
 }
 
-func AdminBuildDatabase(w http.ResponseWriter, r *http.Request) {
-  sess, err := globalSessions.SessionStart(w, r)
+// Renders the admin home page along with the state of the database schema
+func (e *Engine) renderAdminIndex(w http.ResponseWriter, content map[string]interface{}) {
+  version, err := e.store.SchemaVersion()
   if err != nil {
-      //need logging here instead of print
-      fmt.Printf("Error, could not start session %v\n", err)
-      return
-  }
-  defer sess.SessionRelease(w)
-  username := sess.Get("username")
-  if username == nil {
-    AdminLogin(w, r)
-  } else {
-    BuildDatabase()
-
-    fmt.Fprintf(w, "<a href=\"/\">Home</a>")
-    fmt.Fprintf(w, "<p>Database built</p>")
-    fmt.Fprintf(w, "<a href=\"/admin/\">Admin Home</a>")
+    content["Error"] = err
   }
+  content["SchemaVersion"] = version
+  content["LatestSchemaVersion"] = LatestSchemaVersion()
+  renderTemplate(w, "admin/index", content)
 }
 
-func AdminCreateIndexes(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) AdminBuildDatabase(w http.ResponseWriter, r *http.Request) {
   sess, err := globalSessions.SessionStart(w, r)
   if err != nil {
       //need logging here instead of print
@@ -56,35 +47,19 @@ func AdminCreateIndexes(w http.ResponseWriter, r *http.Request) {
   if username == nil {
     AdminLogin(w, r)
   } else {
-    CreateIndexes()
-
-    fmt.Fprintf(w, "<a href=\"/\">Home</a>")
-    fmt.Fprintf(w, "<p>Indexes built</p>")
-    fmt.Fprintf(w, "<a href=\"/admin/\">Admin Home</a>")
-  }
-}
+    content := make(map[string]interface{})
+    content["Title"] = "Admin Home Page"
 
-func AdminAddStopwords(w http.ResponseWriter, r *http.Request) {
-  sess, err := globalSessions.SessionStart(w, r)
-  if err != nil {
-      //need logging here instead of print
-      fmt.Printf("Error, could not start session %v\n", err)
-      return
-  }
-  defer sess.SessionRelease(w)
-  username := sess.Get("username")
-  if username == nil {
-    AdminLogin(w, r)
-  } else {
-    AddStopwords()
+    err := BuildDatabase(e.store)
+    if err != nil {
+      content["Error"] = err
+    }
 
-    fmt.Fprintf(w, "<a href=\"/\">Home</a>")
-    fmt.Fprintf(w, "<p>Stopwords support added</p>")
-    fmt.Fprintf(w, "<a href=\"/admin/\">Admin Home</a>")
+    e.renderAdminIndex(w, content)
   }
 }
 
-func AdminClearData(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) AdminClearData(w http.ResponseWriter, r *http.Request) {
   sess, err := globalSessions.SessionStart(w, r)
   if err != nil {
       //need logging here instead of print
@@ -99,12 +74,12 @@ func AdminClearData(w http.ResponseWriter, r *http.Request) {
     content := make(map[string]interface{})
     content["Title"] = "Admin Home Page"
 
-    err := ClearData()
+    err := e.store.ClearData()
     if err != nil {
       content["Error"] = err
     }
 
-    renderTemplate(w, "admin/index", content)
+    e.renderAdminIndex(w, content)
   }
 }
 
@@ -142,4 +117,23 @@ func AdminLogout(w http.ResponseWriter, r *http.Request) {
     globalSessions.SessionDestroy(w, r)
     content := make(map[string]interface{})
     renderTemplate(w, "admin/login", content)
+}
+
+// Reports whether a JSON API request is from an admin, either logged in to
+// the admin pages or sending the admin username and password as HTTP Basic
+// auth.
+func adminAuthorized(w http.ResponseWriter, r *http.Request) bool {
+  admin_username := os.Getenv("ADMIN_USERNAME")
+  admin_password := os.Getenv("ADMIN_PASSWORD")
+  if username, password, ok := r.BasicAuth(); ok {
+    return admin_username != "" && username == admin_username && password == admin_password
+  }
+
+  sess, err := globalSessions.SessionStart(w, r)
+  if err != nil {
+    fmt.Printf("Error, could not start session %v\n", err)
+    return false
+  }
+  defer sess.SessionRelease(w)
+  return sess.Get("username") != nil
 }
\ No newline at end of file
@@ -0,0 +1,109 @@
+package main
+
+import (
+  "fmt"
+  "net/http"
+  "strconv"
+
+  "github.com/gorilla/mux"
+)
+
+// Renders the aliases admin page
+func (e *Engine) renderAdminAliases(w http.ResponseWriter, content map[string]interface{}) {
+  aliases, err := e.store.Aliases()
+  if err != nil {
+    content["Error"] = "Aliases database table not yet created"
+  } else {
+    content["Aliases"] = aliases
+  }
+  content["Title"] = "Aliases Admin"
+  renderTemplate(w, "admin/aliases/index", content)
+}
+
+// Aliases admin home page
+func (e *Engine) AdminAliases(w http.ResponseWriter, r *http.Request) {
+  sess, err := globalSessions.SessionStart(w, r)
+  if err != nil {
+      //need logging here instead of print
+      fmt.Printf("Error, could not start session %v\n", err)
+      return
+  }
+  defer sess.SessionRelease(w)
+  username := sess.Get("username")
+  if username == nil {
+    AdminLogin(w, r)
+  } else {
+    content := make(map[string]interface{})
+    e.renderAdminAliases(w, content)
+  }
+}
+
+// Adds the terms typed into the admin form, one per line or comma
+// separated, as aliases of a canonical term.
+func (e *Engine) AdminCreateAliases(w http.ResponseWriter, r *http.Request) {
+  sess, err := globalSessions.SessionStart(w, r)
+  if err != nil {
+      //need logging here instead of print
+      fmt.Printf("Error, could not start session %v\n", err)
+      return
+  }
+  defer sess.SessionRelease(w)
+  username := sess.Get("username")
+  if username == nil {
+    AdminLogin(w, r)
+  } else {
+    content := make(map[string]interface{})
+
+    aliases, err := NewAliases(r.PostFormValue("canonical"), ParseTermList(r.PostFormValue("terms")))
+    if err == nil {
+      var existing Aliases
+      existing, err = e.store.Aliases()
+      if err == nil {
+        err = existing.Map().Check(aliases)
+      }
+    }
+    if err != nil {
+      content["AliasError"] = err.Error()
+    } else if len(aliases) == 0 {
+      content["AliasError"] = "No aliases given"
+    } else {
+      added, err := e.store.InsertAliases(aliases)
+      if err != nil {
+        content["AliasError"] = "Could not add aliases"
+      } else {
+        content["Added"] = fmt.Sprintf("Added %d of %d aliases, the rest were already aliases of %s", added, len(aliases), aliases[0].Canonical)
+      }
+    }
+
+    e.renderAdminAliases(w, content)
+  }
+}
+
+func (e *Engine) AdminDeleteAlias(w http.ResponseWriter, r *http.Request) {
+  sess, err := globalSessions.SessionStart(w, r)
+  if err != nil {
+      //need logging here instead of print
+      fmt.Printf("Error, could not start session %v\n", err)
+      return
+  }
+  defer sess.SessionRelease(w)
+  username := sess.Get("username")
+  if username == nil {
+    AdminLogin(w, r)
+  } else {
+    content := make(map[string]interface{})
+
+    method := r.PostFormValue("_method")
+    if ((r.Method == "DELETE") || (r.Method == "POST") && (method == "DELETE")) {
+      vars := mux.Vars(r)
+      uid, _ := strconv.ParseInt(vars["uid"], 10, 0)
+      _, derr := e.store.DeleteAlias(int(uid))
+
+      if (derr != nil) {
+        content["AliasError"] = "Could not delete alias"
+      }
+    }
+
+    e.renderAdminAliases(w, content)
+  }
+}
\ No newline at end of file
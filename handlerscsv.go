@@ -8,10 +8,11 @@ import (
   "github.com/gorilla/mux"
   "encoding/csv"
   "bytes"
+  "strings"
 )
 
 // Generates CSV file of the sources for a trend
-func TrendSourcesCSV(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) TrendSourcesCSV(w http.ResponseWriter, r *http.Request) {
   vars := mux.Vars(r)
   location := vars["location"]
   source := r.URL.Query().Get("source")
@@ -46,20 +47,39 @@ func TrendSourcesCSV(w http.ResponseWriter, r *http.Request) {
     interval = 2
   }
 
-  termPackage := TrendsCollection(source,location, term, fromParam, toParam, interval, velocityInterval, minimumVelocity)
+  // Export every source unless asked for fewer
+  sourcesLimit, _ := strconv.ParseInt(r.URL.Query().Get("sources_limit"), 10, 0)
+  if sourcesLimit < 1 {
+    sourcesLimit = MaxSourcesLimit
+  }
+
+  termPackage, err := TrendsCollection(e.store, source,location, term, fromParam, toParam, interval, velocityInterval, minimumVelocity, 0, int(sourcesLimit), "", 0)
+  if err != nil {
+    renderCollectionError(w, err)
+    return
+  }
 
   b := &bytes.Buffer{} // creates IO Writer
   wr := csv.NewWriter(b) // creates a csv writer that uses the io buffer.
 
-  wr.Write([]string{ "Source", "Location", "Posted", "Source URI"})
+  // A term with aliases is exported as its canonical term, with the
+  // component terms each post used in an extra column
+  header := []string{ "Source", "Location", "Posted", "Source URI"}
+  if termPackage.Forms != nil {
+    header = append(header, "Terms")
+  }
+  wr.Write(header)
   for _, source := range termPackage.Sources {
     record := []string{ source.Source, source.Location, source.Posted.Format("2006-01-02 15:04:05"), source.SourceURI }
+    if termPackage.Forms != nil {
+      record = append(record, strings.Join(source.Terms, "; "))
+    }
     wr.Write(record) // converts array of string to comma seperated values for 1 row.
   }
   wr.Flush() // writes the csv writer data to  the buffered data io writer(b(bytes.buffer))
 
   w.Header().Set("Content-Type", "text/csv")
-  w.Header().Set("Content-Disposition", "attachment;filename=" + term +  ".csv")
+  w.Header().Set("Content-Disposition", "attachment;filename=" + termPackage.Term +  ".csv")
   w.Write(b.Bytes())
 
   w.Header().Add("Access-Control-Allow-Origin", "*")
@@ -1,25 +1,48 @@
 package main
 
 import (
-  //"fmt"
+  "fmt"
   "net/http"
   "time"
   "encoding/json"
   "strconv"
+  "strings"
   "github.com/gorilla/mux"
 )
 
+// Writes message as a JSON error body with the given status
+func renderJSONError(w http.ResponseWriter, status int, message string) {
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// Returns the status to answer a collection's error with, a 400 for a
+// ValidationError and a 500 for anything else, such as a failure reading
+// the store
+func collectionErrorStatus(err error) int {
+  if _, ok := err.(ValidationError); ok {
+    return http.StatusBadRequest
+  }
+  return http.StatusInternalServerError
+}
+
+// Writes the error a collection returned, see collectionErrorStatus
+func renderCollectionError(w http.ResponseWriter, err error) {
+  renderJSONError(w, collectionErrorStatus(err), err.Error())
+}
+
 // Generates JSON list of locations
-func RenderLocationsJSON(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) RenderLocationsJSON(w http.ResponseWriter, r *http.Request) {
   w.Header().Add("Access-Control-Allow-Origin", "*")
   w.Header().Add("Access-Control-Allow-Methods", "GET")
   w.Header().Add("Access-Control-Allow-Headers", "Content-Type, api_key, Authorization")
-  locations, _ := BuildLocationsList()
+  locations, _ := BuildLocationsList(e.store)
   json.NewEncoder(w).Encode(locations)
 }
 
 // Generates JSON stats for a location
-func RenderLocationStatsJSON(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) RenderLocationStatsJSON(w http.ResponseWriter, r *http.Request) {
   vars := mux.Vars(r)
   location := vars["location"]
   source := r.URL.Query().Get("source")
@@ -38,7 +61,11 @@ func RenderLocationStatsJSON(w http.ResponseWriter, r *http.Request) {
   if interval < 1 {
     interval = 2
   }
-  wordCounts, _ := WordCountRootCollection(location, source, fromParam, toParam, int(interval), 1000)
+  wordCounts, err := WordCountRootCollection(e.store, location, source, fromParam, toParam, int(interval), 1000, UnigramsOnly, nil, "", "")
+  if err != nil {
+    renderCollectionError(w, err)
+    return
+  }
 
   totalCounts := map[string]int {}
 
@@ -58,7 +85,7 @@ func RenderLocationStatsJSON(w http.ResponseWriter, r *http.Request) {
 }
 
 // Generates JSON for root list of trends
-func TrendsRootIndex(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) TrendsRootIndex(w http.ResponseWriter, r *http.Request) {
   vars := mux.Vars(r)
   location := vars["location"]
   source := r.URL.Query().Get("source")
@@ -82,7 +109,35 @@ func TrendsRootIndex(w http.ResponseWriter, r *http.Request) {
   if interval < 1 {
     interval = 2
   }
-  sortedCounts, _ := WordCountRootCollection(location, source, fromParam, toParam, int(interval), int(limit))
+  if interval > MaxInterval {
+    renderJSONError(w, http.StatusBadRequest, fmt.Sprintf("interval must be at most %d", MaxInterval))
+    return
+  }
+  ngram, err := ParseNgramRange(r.URL.Query().Get("ngram"))
+  if err != nil {
+    renderJSONError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  stemmer, err := StemmerFor(r.URL.Query().Get("stem"))
+  if err != nil {
+    renderJSONError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  lang := strings.ToLower(r.URL.Query().Get("lang"))
+  if lang != "" && !isLanguageCode(lang) {
+    renderJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid lang %q, expected an ISO 639 code such as en", lang))
+    return
+  }
+  algorithm := r.URL.Query().Get("algorithm")
+  if _, err := ScorerFor(algorithm); err != nil {
+    renderJSONError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  sortedCounts, err := WordCountRootCollection(e.store, location, source, fromParam, toParam, int(interval), int(limit), ngram, stemmer, lang, algorithm)
+  if err != nil {
+    renderCollectionError(w, err)
+    return
+  }
 
   w.Header().Add("Access-Control-Allow-Origin", "*")
   w.Header().Add("Access-Control-Allow-Methods", "GET")
@@ -91,7 +146,7 @@ func TrendsRootIndex(w http.ResponseWriter, r *http.Request) {
 }
 
 // Generates JSON list of trends for a term
-func TrendsIndex(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) TrendsIndex(w http.ResponseWriter, r *http.Request) {
   vars := mux.Vars(r)
   location := vars["location"]
   source := r.URL.Query().Get("source")
@@ -126,10 +181,200 @@ func TrendsIndex(w http.ResponseWriter, r *http.Request) {
     interval = 2
   }
 
-  termPackage := TrendsCollection(source,location, term, fromParam, toParam, interval, velocityInterval, minimumVelocity)
+  relatedLimit, _ := strconv.ParseInt(r.URL.Query().Get("related_limit"), 10, 0)
+  sourcesLimit, _ := strconv.ParseInt(r.URL.Query().Get("sources_limit"), 10, 0)
+  relatedScoring, err := RelatedScoringFor(r.URL.Query().Get("related_score"))
+  if err != nil {
+    renderJSONError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  relatedMinSupport, _ := strconv.ParseInt(r.URL.Query().Get("related_min_support"), 10, 0)
+
+  termPackage, err := TrendsCollection(e.store, source,location, term, fromParam, toParam, interval, velocityInterval, minimumVelocity, int(relatedLimit), int(sourcesLimit), relatedScoring, int(relatedMinSupport))
+  if err != nil {
+    renderCollectionError(w, err)
+    return
+  }
 
   w.Header().Add("Access-Control-Allow-Origin", "*")
   w.Header().Add("Access-Control-Allow-Methods", "GET")
   w.Header().Add("Access-Control-Allow-Headers", "Content-Type, api_key, Authorization")
   json.NewEncoder(w).Encode(termPackage)
+}
+
+// Generates JSON list of the terms emerging in a location
+func (e *Engine) EmergingTermsIndex(w http.ResponseWriter, r *http.Request) {
+  vars := mux.Vars(r)
+  location := vars["location"]
+  query := r.URL.Query()
+  source := query.Get("source")
+
+  limit, _ := strconv.ParseInt(query.Get("limit"), 10, 0)
+  if limit < 1 {
+    limit = DefaultEmergingLimit
+  }
+  minOccurrences, err := strconv.ParseInt(query.Get("min_occurrences"), 10, 0)
+  if err != nil {
+    minOccurrences = DefaultEmergingMinimum
+  }
+  minNovelty, err := strconv.ParseFloat(query.Get("min_novelty"), 64)
+  if err != nil {
+    minNovelty = SignificantLogLikelihood
+  }
+  ngram, err := ParseNgramRange(query.Get("ngram"))
+  if err != nil {
+    renderJSONError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  lang := strings.ToLower(query.Get("lang"))
+  if lang != "" && !isLanguageCode(lang) {
+    renderJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid lang %q, expected an ISO 639 code such as en", lang))
+    return
+  }
+
+  emerging, err := EmergingTermsCollection(e.store, location, source, query.Get("from"), query.Get("to"), query.Get("baseline_from"), query.Get("baseline_to"), int(limit), int(minOccurrences), minNovelty, ngram, lang)
+  if err != nil {
+    renderCollectionError(w, err)
+    return
+  }
+
+  w.Header().Add("Access-Control-Allow-Origin", "*")
+  w.Header().Add("Access-Control-Allow-Methods", "GET")
+  w.Header().Add("Access-Control-Allow-Headers", "Content-Type, api_key, Authorization")
+  json.NewEncoder(w).Encode(emerging)
+}
+
+// Generates JSON list of the topics the trending terms in a location fall
+// into
+func (e *Engine) TopicsIndex(w http.ResponseWriter, r *http.Request) {
+  vars := mux.Vars(r)
+  query := r.URL.Query()
+
+  interval, _ := strconv.ParseInt(query.Get("interval"), 10, 0)
+  limit, _ := strconv.ParseInt(query.Get("limit"), 10, 0)
+  minCoOccurrence, _ := strconv.ParseInt(query.Get("min_cooccurrence"), 10, 0)
+  minSize, _ := strconv.ParseInt(query.Get("min_size"), 10, 0)
+  sourcesLimit, _ := strconv.ParseInt(query.Get("sources_limit"), 10, 0)
+  ngram, err := ParseNgramRange(query.Get("ngram"))
+  if err != nil {
+    renderJSONError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  lang := strings.ToLower(query.Get("lang"))
+  if lang != "" && !isLanguageCode(lang) {
+    renderJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid lang %q, expected an ISO 639 code such as en", lang))
+    return
+  }
+  algorithm := query.Get("algorithm")
+  if _, err := ScorerFor(algorithm); err != nil {
+    renderJSONError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+
+  topics, err := TopicsCollection(e.store, vars["location"], query.Get("source"), query.Get("from"), query.Get("to"), int(interval), int(limit), int(minCoOccurrence), int(minSize), int(sourcesLimit), ngram, lang, algorithm)
+  if err != nil {
+    renderCollectionError(w, err)
+    return
+  }
+
+  w.Header().Add("Access-Control-Allow-Origin", "*")
+  w.Header().Add("Access-Control-Allow-Methods", "GET")
+  w.Header().Add("Access-Control-Allow-Headers", "Content-Type, api_key, Authorization")
+  json.NewEncoder(w).Encode(topics)
+}
+
+// Generates JSON comparison of a term's trends in the locations listed,
+// comma separated, in the locations parameter
+func (e *Engine) TermCompare(w http.ResponseWriter, r *http.Request) {
+  vars := mux.Vars(r)
+  query := r.URL.Query()
+
+  locations := []string {}
+  for _, location := range strings.Split(query.Get("locations"), ",") {
+    location = strings.TrimSpace(location)
+    if location != "" && !stringInSlice(location, locations) {
+      locations = append(locations, location)
+    }
+  }
+  interval, _ := strconv.ParseInt(query.Get("interval"), 10, 0)
+
+  comparison, err := TermComparisonCollection(e.store, query.Get("source"), locations, vars["term"], query.Get("from"), query.Get("to"), int(interval))
+  if err != nil {
+    renderCollectionError(w, err)
+    return
+  }
+
+  w.Header().Add("Access-Control-Allow-Origin", "*")
+  w.Header().Add("Access-Control-Allow-Methods", "GET")
+  w.Header().Add("Access-Control-Allow-Headers", "Content-Type, api_key, Authorization")
+  json.NewEncoder(w).Encode(comparison)
+}
+
+// Generates JSON of how a term spread between locations
+func (e *Engine) TermDiffusionIndex(w http.ResponseWriter, r *http.Request) {
+  vars := mux.Vars(r)
+  query := r.URL.Query()
+
+  interval, _ := strconv.ParseInt(query.Get("interval"), 10, 0)
+
+  diffusion, err := TermDiffusionCollection(e.store, query.Get("source"), vars["term"], query.Get("from"), query.Get("to"), int(interval))
+  if err != nil {
+    renderCollectionError(w, err)
+    return
+  }
+
+  w.Header().Add("Access-Control-Allow-Origin", "*")
+  w.Header().Add("Access-Control-Allow-Methods", "GET")
+  w.Header().Add("Access-Control-Allow-Headers", "Content-Type, api_key, Authorization")
+  json.NewEncoder(w).Encode(diffusion)
+}
+
+// Generates JSON of the trends of the terms listed, comma separated, in
+// the terms parameter, over the same buckets
+func (e *Engine) TermChartIndex(w http.ResponseWriter, r *http.Request) {
+  vars := mux.Vars(r)
+  query := r.URL.Query()
+
+  interval, _ := strconv.ParseInt(query.Get("interval"), 10, 0)
+
+  chart, err := TermChartCollection(e.store, query.Get("source"), vars["location"], ParseTermList(query.Get("terms")), query.Get("from"), query.Get("to"), int(interval))
+  if err != nil {
+    renderCollectionError(w, err)
+    return
+  }
+
+  w.Header().Add("Access-Control-Allow-Origin", "*")
+  w.Header().Add("Access-Control-Allow-Methods", "GET")
+  w.Header().Add("Access-Control-Allow-Headers", "Content-Type, api_key, Authorization")
+  json.NewEncoder(w).Encode(chart)
+}
+
+// Generates JSON forecast of a term's series
+func (e *Engine) TrendForecast(w http.ResponseWriter, r *http.Request) {
+  vars := mux.Vars(r)
+  query := r.URL.Query()
+
+  interval, _ := strconv.ParseInt(query.Get("interval"), 10, 0)
+  horizon, _ := strconv.ParseInt(query.Get("horizon"), 10, 0)
+  season := int64(-1)
+  if query.Get("season") != "" {
+    var err error
+    season, err = strconv.ParseInt(query.Get("season"), 10, 0)
+    if err != nil || season < 0 {
+      renderJSONError(w, http.StatusBadRequest, "season must be a whole number of intervals, 0 for none")
+      return
+    }
+  }
+  confidence, _ := strconv.ParseFloat(query.Get("confidence"), 64)
+
+  forecast, err := TermForecastCollection(e.store, query.Get("source"), vars["location"], vars["term"], query.Get("from"), query.Get("to"), int(interval), int(horizon), int(season), confidence, query.Get("model"))
+  if err != nil {
+    renderCollectionError(w, err)
+    return
+  }
+
+  w.Header().Add("Access-Control-Allow-Origin", "*")
+  w.Header().Add("Access-Control-Allow-Methods", "GET")
+  w.Header().Add("Access-Control-Allow-Headers", "Content-Type, api_key, Authorization")
+  json.NewEncoder(w).Encode(forecast)
 }
\ No newline at end of file
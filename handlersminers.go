@@ -1,43 +1,37 @@
 package main
 
 import (
+  "database/sql"
   "fmt"
   "net/http"
   "encoding/json"
   "bytes"
+  "io/ioutil"
   "strconv"
+  "time"
   "github.com/gorilla/mux"
 )
 
-func GetMiner(id int) (miner Miner, err error) {
-  
-  rows := QueryMinerForId(id)
-  for rows.Next() {
-    var uid int
-    var name string
-    var source string
-    var location string
-    var url string
-    var geoCoord Point
-    var locationHash int
-    var stopwords string
-    err := rows.Scan(&uid, &name, &source, &location, &url, &geoCoord, &locationHash, &stopwords)
-    checkErr(err)
-    miner = Miner {
-      Uid: uid,
-      Name: name,
-      Source: source,
-      Location: location,
-      GeoCoord: geoCoord,
-      Url: url,
-      Stopwords: stopwords,
-    }
+// Largest minerpost body accepted, in bytes
+const MaxMinerPostSize = 32 << 20
+
+// Builds a Miner from the fields of the admin miner form
+func minerFromForm(r *http.Request) Miner {
+  latitude, _ := strconv.ParseFloat(r.PostFormValue("latitude"), 64)
+  longitude, _ := strconv.ParseFloat(r.PostFormValue("longitude"), 64)
+
+  return Miner {
+    Name: r.PostFormValue("name"),
+    Url: r.PostFormValue("url"),
+    Location: r.PostFormValue("location"),
+    GeoCoord: *NewPoint(latitude, longitude),
+    Source: r.PostFormValue("source"),
+    Stopwords: r.PostFormValue("stopwords"),
   }
-  return
 }
 
 // Miners admin home page
-func AdminMiners(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) AdminMiners(w http.ResponseWriter, r *http.Request) {
 
   sess, err := globalSessions.SessionStart(w, r)
   if err != nil {
@@ -50,7 +44,7 @@ func AdminMiners(w http.ResponseWriter, r *http.Request) {
   if username == nil {
     AdminLogin(w, r)
   } else {
-    miners, err :=  MinersCollection()
+    miners, err :=  e.store.Miners()
     content := make(map[string]interface{})
 
     content["Title"] = "Miners Admin"
@@ -82,7 +76,7 @@ func AdminNewMiner(w http.ResponseWriter, r *http.Request) {
   }
 }
 
-func AdminEditMiner(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) AdminEditMiner(w http.ResponseWriter, r *http.Request) {
   sess, err := globalSessions.SessionStart(w, r)
   if err != nil {
       //need logging here instead of print
@@ -100,7 +94,7 @@ func AdminEditMiner(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     uidConv := vars["uid"]
     uid, _ := strconv.ParseInt(uidConv, 10, 0)
-    miner, err := GetMiner(int(uid))
+    miner, err := e.store.Miner(int(uid))
 
     if err != nil {
       content["Error"] = "Could not retrieve miner"
@@ -113,7 +107,7 @@ func AdminEditMiner(w http.ResponseWriter, r *http.Request) {
   }
 }
 
-func AdminUpdateMiner(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) AdminUpdateMiner(w http.ResponseWriter, r *http.Request) {
   sess, err := globalSessions.SessionStart(w, r)
   if err != nil {
       //need logging here instead of print
@@ -142,24 +136,25 @@ func AdminUpdateMiner(w http.ResponseWriter, r *http.Request) {
     latitude := r.PostFormValue("latitude")
     longitude := r.PostFormValue("longitude")
     source := r.PostFormValue("source")
-    stopwords := r.PostFormValue("stopwords")
 
     if (name == "") || (url == "") || (location == "") || (latitude == "") || (longitude == "") || (source == "") {
       vars := mux.Vars(r)
       uidConv := vars["uid"]
       uid, _ := strconv.ParseInt(uidConv, 10, 0)
-      miner, _ := GetMiner(int(uid))
+      miner, _ := e.store.Miner(int(uid))
       content["Miner"] = miner
       content["Title"] = "Miners Admin: Edit Miner"
       content["Error"] = "Can't update - one or more fields are blank"
       renderTemplate(w, "admin/miners/edit", content)
     } else {
       content["Title"] = "Miners Admin"
-      _, err = UpdateMiner(name, location, latitude, longitude, source, url, stopwords, int(uid))
+      miner := minerFromForm(r)
+      miner.Uid = int(uid)
+      _, err = e.store.UpdateMiner(miner)
       if err != nil {
         content["MinerError"] = err
       }
-      miners, err :=  MinersCollection()
+      miners, err :=  e.store.Miners()
       if err != nil {
         content["Error"] = "Miners database table not yet created"
       } else {
@@ -170,7 +165,7 @@ func AdminUpdateMiner(w http.ResponseWriter, r *http.Request) {
   }
 }
 
-func AdminDeleteMiner(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) AdminDeleteMiner(w http.ResponseWriter, r *http.Request) {
   sess, err := globalSessions.SessionStart(w, r)
   if err != nil {
       //need logging here instead of print
@@ -190,7 +185,7 @@ func AdminDeleteMiner(w http.ResponseWriter, r *http.Request) {
       vars := mux.Vars(r)
       uidConv := vars["uid"]
       uid, _ := strconv.ParseInt(uidConv, 10, 0)
-      _, derr := DeleteMiner(int(uid))
+      _, derr := e.store.DeleteMiner(int(uid))
 
       if (derr != nil) {
         content["Error"] = "Could not delete miner" 
@@ -198,7 +193,7 @@ func AdminDeleteMiner(w http.ResponseWriter, r *http.Request) {
     }
 
     //Get remaining collection
-    miners, err :=  MinersCollection()
+    miners, err :=  e.store.Miners()
     if err != nil {
       content["Error"] = "Miners database table not yet created"
     } else {
@@ -209,7 +204,7 @@ func AdminDeleteMiner(w http.ResponseWriter, r *http.Request) {
   }
 }
 
-func AdminMinersResetDatabase(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) AdminMinersResetDatabase(w http.ResponseWriter, r *http.Request) {
 
   sess, err := globalSessions.SessionStart(w, r)
   if err != nil {
@@ -222,14 +217,14 @@ func AdminMinersResetDatabase(w http.ResponseWriter, r *http.Request) {
   if username == nil {
     AdminLogin(w, r)
   } else {
-    ResetMinersDatabase()
+    e.store.ResetMiners()
 
-    AdminMiners(w, r)
+    e.AdminMiners(w, r)
   }
 }
 
 // Creates a new miner
-func AdminCreateMiner(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) AdminCreateMiner(w http.ResponseWriter, r *http.Request) {
   sess, err := globalSessions.SessionStart(w, r)
   if err != nil {
       //need logging here instead of print
@@ -249,18 +244,23 @@ func AdminCreateMiner(w http.ResponseWriter, r *http.Request) {
 
     content := make(map[string]interface{})
 
-    name := r.PostFormValue("name")
-    url := r.PostFormValue("url")
-    location := r.PostFormValue("location")
-    latitude := r.PostFormValue("latitude")
-    longitude := r.PostFormValue("longitude")
-    source := r.PostFormValue("source")
-    stopwords := r.PostFormValue("stopwords")
-    lastInsertId, err := InsertMiner(name, location, latitude, longitude, source, url, stopwords)
+    miner := minerFromForm(r)
+    secret, err := NewMinerSecret()
+    if err != nil {
+      fmt.Println("Error:", err)
+      http.Error(w, err.Error(), http.StatusInternalServerError)
+      return
+    }
+    miner.KeyHash = MinerKeyHash(secret)
+    lastInsertId, err := e.store.InsertMiner(miner)
     if err != nil {
       content["MinerError"] = err
+    } else {
+      // The secret is only ever shown here and when it is rotated
+      content["NewSecret"] = secret
+      content["NewSecretMinerId"] = lastInsertId
     }
-    sendIdUrl := fmt.Sprintf("%s/categories", url)
+    sendIdUrl := fmt.Sprintf("%s/categories", miner.Url)
     idData := fmt.Sprintf("{\"id\":\"%d\"}", lastInsertId)
 
     var jsonStr = []byte(idData)
@@ -278,7 +278,52 @@ func AdminCreateMiner(w http.ResponseWriter, r *http.Request) {
       content["MinerError"] = err
     }
 
-    miners, err :=  MinersCollection()
+    miners, err :=  e.store.Miners()
+    if err != nil {
+      content["Error"] = "Miners database table not yet created"
+    } else {
+      content["Miners"] = miners
+    }
+
+    renderTemplate(w, "admin/miners/index", content)
+  }
+}
+
+// Replaces a miner's API key and shows the new one
+func (e *Engine) AdminRotateMinerSecret(w http.ResponseWriter, r *http.Request) {
+  sess, err := globalSessions.SessionStart(w, r)
+  if err != nil {
+      //need logging here instead of print
+      fmt.Printf("Error, could not start session %v\n", err)
+      return
+  }
+  defer sess.SessionRelease(w)
+  username := sess.Get("username")
+  if username == nil {
+    AdminLogin(w, r)
+  } else {
+    content := make(map[string]interface{})
+    content["Title"] = "Miners Admin"
+
+    vars := mux.Vars(r)
+    uid, _ := strconv.ParseInt(vars["uid"], 10, 0)
+    secret, err := NewMinerSecret()
+    if err != nil {
+      fmt.Println("Error:", err)
+      http.Error(w, err.Error(), http.StatusInternalServerError)
+      return
+    }
+    affected, err := e.store.SetMinerKeyHash(int(uid), MinerKeyHash(secret))
+    if err != nil {
+      content["MinerError"] = err
+    } else if affected == 0 {
+      content["MinerError"] = "Could not find miner"
+    } else {
+      content["NewSecret"] = secret
+      content["NewSecretMinerId"] = uid
+    }
+
+    miners, err :=  e.store.Miners()
     if err != nil {
       content["Error"] = "Miners database table not yet created"
     } else {
@@ -289,67 +334,108 @@ func AdminCreateMiner(w http.ResponseWriter, r *http.Request) {
   }
 }
 
-// Handles receipt of post from a Miner
-func MinerPost(w http.ResponseWriter, r *http.Request) {
-  decoder := json.NewDecoder(r.Body)
+// Handles receipt of post from a Miner. The posts are queued for storing and
+// the miner is given a batch id to follow their progress with.
+func (e *Engine) MinerPost(w http.ResponseWriter, r *http.Request) {
+  body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxMinerPostSize))
+  if err != nil {
+    renderJSONError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+
   var posts MinerPostsJSON
-  err := decoder.Decode(&posts)
+  err = json.Unmarshal(body, &posts)
   if err != nil {
     fmt.Println("Error:", err)
-    http.Error(w, err.Error(), 500)
+    renderJSONError(w, http.StatusBadRequest, err.Error())
     return
   }
 
-  http.Error(w, "OK", 200)
-
-
-
-  //fmt.Println("Post from Miner Id: ", posts.MinerId)
-  //fmt.Println(posts)
-
-  minerConv, _ := strconv.ParseInt(posts.MinerId, 10, 0)
-  var miner Miner
-  rows := QueryMinerForId(int(minerConv))
-  for rows.Next() {
-    var uid int
-    var name string
-    var source string
-    var location string
-    var url string
-    var geoCoord Point
-    var locationHash int
-    var stopwords string
-    err := rows.Scan(&uid, &name, &source, &location, &url, &geoCoord, &locationHash, &stopwords)
-    checkErr(err)
-    miner = Miner {
-      Uid: uid,
-      Name: name,
-      Source: source,
-      Location: location,
-      GeoCoord: geoCoord,
-      Url: url,
-      Stopwords: stopwords,
-    }
+  miner, ok := e.authenticatedMiner(w, r, posts.MinerId)
+  if !ok {
+    return
   }
 
-  for _, post := range posts.Posts {
-    url := post.Url
-    posted := post.Datetime
-    mined := post.MinedAt
-    /*
-    fmt.Println("SourceURI ", url)
-    fmt.Println("Posted ", posted)
-    fmt.Println("Mined ", mined)
-    fmt.Println("Terms and their counts")
-    fmt.Println("Miner location ", miner.Location)
-    fmt.Println("Miner source", miner.Source)
-    */
-    lastInsertId := InsertPost(miner.Source, miner.Location, url, posted.Time, mined.Time)
-    if lastInsertId != 0 {
-      for k, v := range post.Terms {
-        //fmt.Println(k, v)
-        InsertTerm(miner.Location, k, v, lastInsertId, posted.Time)
-      }
+  response := MinerPostResponse{Results: []MinerPostResult {}}
+  valid := []MinerPostJSON {}
+  now := time.Now()
+  // Managed stopwords are left in the stored terms and only applied when
+  // trends are read, so changing them applies to posts already stored
+  tokenizer := NewTokenizer(e.tokenizer)
+  for index, raw := range posts.Posts {
+    post, result := ValidateMinerPost(index, raw, now, tokenizer)
+    if result.Valid {
+      valid = append(valid, post)
     }
+    response.Results = append(response.Results, result)
+  }
+
+  w.Header().Set("Content-Type", "application/json")
+  if len(valid) == 0 {
+    response.Error = "No valid posts to store"
+    w.WriteHeader(http.StatusUnprocessableEntity)
+    json.NewEncoder(w).Encode(response)
+    return
+  }
+
+  status, err := e.ingester.Enqueue(miner, valid, len(posts.Posts) - len(valid))
+  if err != nil {
+    renderJSONError(w, http.StatusServiceUnavailable, err.Error())
+    return
+  }
+  response.BatchStatus = &status
+
+  w.Header().Set("Location", "/v1/minerpost/" + status.BatchId)
+  w.WriteHeader(http.StatusAccepted)
+  json.NewEncoder(w).Encode(response)
+}
+
+// Reports the progress of a batch of posts sent by a Miner. The miner named
+// by the miner_id parameter authenticates as for MinerPost and can only see
+// its own batches.
+func (e *Engine) MinerPostStatus(w http.ResponseWriter, r *http.Request) {
+  vars := mux.Vars(r)
+  miner, ok := e.authenticatedMiner(w, r, r.URL.Query().Get("miner_id"))
+  if !ok {
+    return
+  }
+
+  status, err := e.ingester.Status(miner.Uid, vars["batchId"])
+  if err == sql.ErrNoRows {
+    renderJSONError(w, http.StatusNotFound, fmt.Sprintf("Unknown batch %s", vars["batchId"]))
+    return
+  }
+  if err != nil {
+    renderJSONError(w, http.StatusInternalServerError, err.Error())
+    return
+  }
+
+  w.Header().Set("Content-Type", "application/json")
+  json.NewEncoder(w).Encode(status)
+}
+
+// Returns the miner minerId names once AuthenticateMiner has checked the
+// request came from it. Otherwise writes a 401, or a 500 when the miner
+// can't be read, and returns false.
+func (e *Engine) authenticatedMiner(w http.ResponseWriter, r *http.Request, minerId string) (Miner, bool) {
+  minerConv, err := strconv.ParseInt(minerId, 10, 0)
+  if err != nil {
+    renderJSONError(w, http.StatusUnauthorized, fmt.Sprintf("Unknown miner_id %q", minerId))
+    return Miner{}, false
+  }
+  miner, err := e.store.Miner(int(minerConv))
+  if err == sql.ErrNoRows {
+    renderJSONError(w, http.StatusUnauthorized, fmt.Sprintf("Unknown miner_id %q", minerId))
+    return miner, false
   }
-}
\ No newline at end of file
+  if err != nil {
+    renderJSONError(w, http.StatusInternalServerError, err.Error())
+    return miner, false
+  }
+
+  if err := AuthenticateMiner(miner, r); err != nil {
+    renderJSONError(w, http.StatusUnauthorized, err.Error())
+    return miner, false
+  }
+  return miner, true
+}
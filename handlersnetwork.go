@@ -0,0 +1,76 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+
+  "github.com/gorilla/mux"
+)
+
+// Generates the co-occurrence network of the terms trending in a location,
+// as JSON nodes and edges or, for Gephi and other graph tools, as a GEXF or
+// GraphML file chosen by the format parameter
+func (e *Engine) NetworkIndex(w http.ResponseWriter, r *http.Request) {
+  vars := mux.Vars(r)
+  location := vars["location"]
+  query := r.URL.Query()
+
+  format := strings.ToLower(query.Get("format"))
+  if format == "" {
+    format = NetworkJSON
+  }
+  if format != NetworkJSON && format != NetworkGEXF && format != NetworkGraphML {
+    renderJSONError(w, http.StatusBadRequest, fmt.Sprintf("Unknown format %s, use one of %s, %s or %s", format, NetworkJSON, NetworkGEXF, NetworkGraphML))
+    return
+  }
+  interval, _ := strconv.ParseInt(query.Get("interval"), 10, 0)
+  limit, _ := strconv.ParseInt(query.Get("limit"), 10, 0)
+  minOccurrences, _ := strconv.ParseInt(query.Get("min_occurrences"), 10, 0)
+  minWeight, _ := strconv.ParseInt(query.Get("min_weight"), 10, 0)
+  isolated, _ := strconv.ParseBool(query.Get("isolated"))
+  ngram, err := ParseNgramRange(query.Get("ngram"))
+  if err != nil {
+    renderJSONError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  lang := strings.ToLower(query.Get("lang"))
+  if lang != "" && !isLanguageCode(lang) {
+    renderJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid lang %q, expected an ISO 639 code such as en", lang))
+    return
+  }
+  algorithm := query.Get("algorithm")
+  if _, err := ScorerFor(algorithm); err != nil {
+    renderJSONError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+
+  network, err := NetworkCollection(e.store, location, query.Get("source"), query.Get("from"), query.Get("to"), int(interval), int(limit), int(minOccurrences), int(minWeight), isolated, ngram, lang, algorithm)
+  if err != nil {
+    renderCollectionError(w, err)
+    return
+  }
+
+  w.Header().Add("Access-Control-Allow-Origin", "*")
+  w.Header().Add("Access-Control-Allow-Methods", "GET")
+  w.Header().Add("Access-Control-Allow-Headers", "Content-Type, api_key, Authorization")
+  filename := location + "-network." + format
+  switch format {
+  case NetworkGEXF:
+    w.Header().Set("Content-Type", "application/gexf+xml")
+    w.Header().Set("Content-Disposition", "attachment;filename=" + filename)
+    err = network.WriteGEXF(w)
+  case NetworkGraphML:
+    w.Header().Set("Content-Type", "application/graphml+xml")
+    w.Header().Set("Content-Disposition", "attachment;filename=" + filename)
+    err = network.WriteGraphML(w)
+  default:
+    w.Header().Set("Content-Type", "application/json")
+    err = json.NewEncoder(w).Encode(network)
+  }
+  if err != nil {
+    fmt.Println(err)
+  }
+}
\ No newline at end of file
@@ -0,0 +1,211 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "net/http"
+  "strconv"
+
+  "github.com/gorilla/mux"
+)
+
+// Largest stopword import accepted, in bytes
+const MaxStopwordImportSize = 1 << 20
+
+// The body of a stopwords API post
+type StopwordsJSON struct {
+  Scope string `json:"scope"`
+  Target string `json:"target"`
+  Words []string `json:"words"`
+}
+
+// Renders the stopwords admin page, filtered by the scope and target
+// query parameters.
+func (e *Engine) renderAdminStopwords(w http.ResponseWriter, r *http.Request, content map[string]interface{}) {
+  scope := r.URL.Query().Get("scope")
+  target := r.URL.Query().Get("target")
+  stopwords, err := e.store.Stopwords(scope, target)
+  if err != nil {
+    content["Error"] = "Stopwords database table not yet created"
+  } else {
+    content["Stopwords"] = stopwords
+  }
+  content["Title"] = "Stopwords Admin"
+  content["Scope"] = scope
+  content["Target"] = target
+  content["Scopes"] = StopwordScopes
+  renderTemplate(w, "admin/stopwords/index", content)
+}
+
+// Stopwords admin home page
+func (e *Engine) AdminStopwords(w http.ResponseWriter, r *http.Request) {
+  sess, err := globalSessions.SessionStart(w, r)
+  if err != nil {
+      //need logging here instead of print
+      fmt.Printf("Error, could not start session %v\n", err)
+      return
+  }
+  defer sess.SessionRelease(w)
+  username := sess.Get("username")
+  if username == nil {
+    AdminLogin(w, r)
+  } else {
+    content := make(map[string]interface{})
+    e.renderAdminStopwords(w, r, content)
+  }
+}
+
+// Adds the stopwords typed into the admin form or uploaded as a file, one
+// per line or comma separated.
+func (e *Engine) AdminCreateStopwords(w http.ResponseWriter, r *http.Request) {
+  sess, err := globalSessions.SessionStart(w, r)
+  if err != nil {
+      //need logging here instead of print
+      fmt.Printf("Error, could not start session %v\n", err)
+      return
+  }
+  defer sess.SessionRelease(w)
+  username := sess.Get("username")
+  if username == nil {
+    AdminLogin(w, r)
+  } else {
+    content := make(map[string]interface{})
+
+    r.Body = http.MaxBytesReader(w, r.Body, MaxStopwordImportSize)
+    err := r.ParseMultipartForm(MaxStopwordImportSize)
+    if err != nil && err != http.ErrNotMultipart {
+      fmt.Println(err)
+    }
+
+    words := ParseTermList(r.FormValue("words"))
+    file, _, ferr := r.FormFile("file")
+    if ferr == nil {
+      defer file.Close()
+      list, rerr := ioutil.ReadAll(file)
+      if rerr != nil {
+        content["StopwordError"] = "Could not read the uploaded file"
+      }
+      words = append(words, ParseTermList(string(list))...)
+    }
+
+    stopwords, err := NewStopwords(r.FormValue("scope"), r.FormValue("target"), words)
+    if err != nil {
+      content["StopwordError"] = err.Error()
+    } else if len(stopwords) == 0 {
+      content["StopwordError"] = "No stopwords given"
+    } else {
+      added, err := e.store.InsertStopwords(stopwords)
+      if err != nil {
+        content["StopwordError"] = "Could not add stopwords"
+      } else {
+        content["Added"] = fmt.Sprintf("Added %d of %d stopwords, the rest were already present", added, len(stopwords))
+      }
+    }
+
+    e.renderAdminStopwords(w, r, content)
+  }
+}
+
+func (e *Engine) AdminDeleteStopword(w http.ResponseWriter, r *http.Request) {
+  sess, err := globalSessions.SessionStart(w, r)
+  if err != nil {
+      //need logging here instead of print
+      fmt.Printf("Error, could not start session %v\n", err)
+      return
+  }
+  defer sess.SessionRelease(w)
+  username := sess.Get("username")
+  if username == nil {
+    AdminLogin(w, r)
+  } else {
+    content := make(map[string]interface{})
+
+    method := r.PostFormValue("_method")
+    if ((r.Method == "DELETE") || (r.Method == "POST") && (method == "DELETE")) {
+      vars := mux.Vars(r)
+      uid, _ := strconv.ParseInt(vars["uid"], 10, 0)
+      _, derr := e.store.DeleteStopword(int(uid))
+
+      if (derr != nil) {
+        content["StopwordError"] = "Could not delete stopword"
+      }
+    }
+
+    e.renderAdminStopwords(w, r, content)
+  }
+}
+
+// Lists stopwords as JSON, filtered by the scope and target query
+// parameters.
+func (e *Engine) StopwordsIndex(w http.ResponseWriter, r *http.Request) {
+  if !adminAuthorized(w, r) {
+    renderJSONError(w, http.StatusUnauthorized, "admin login required")
+    return
+  }
+
+  stopwords, err := e.store.Stopwords(r.URL.Query().Get("scope"), r.URL.Query().Get("target"))
+  if err != nil {
+    renderJSONError(w, http.StatusInternalServerError, err.Error())
+    return
+  }
+  w.Header().Set("Content-Type", "application/json")
+  json.NewEncoder(w).Encode(stopwords)
+}
+
+// Adds or bulk imports stopwords posted as JSON for one scope and target.
+func (e *Engine) StopwordsCreate(w http.ResponseWriter, r *http.Request) {
+  if !adminAuthorized(w, r) {
+    renderJSONError(w, http.StatusUnauthorized, "admin login required")
+    return
+  }
+
+  var body StopwordsJSON
+  err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxStopwordImportSize)).Decode(&body)
+  if err != nil {
+    renderJSONError(w, http.StatusBadRequest, fmt.Sprintf("Could not decode stopwords: %v", err))
+    return
+  }
+
+  stopwords, err := NewStopwords(body.Scope, body.Target, body.Words)
+  if err != nil {
+    renderJSONError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  if len(stopwords) == 0 {
+    renderJSONError(w, http.StatusBadRequest, "words must not be empty")
+    return
+  }
+
+  added, err := e.store.InsertStopwords(stopwords)
+  if err != nil {
+    renderJSONError(w, http.StatusInternalServerError, err.Error())
+    return
+  }
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(http.StatusCreated)
+  json.NewEncoder(w).Encode(map[string]int{"added": added, "skipped": len(stopwords) - added})
+}
+
+func (e *Engine) StopwordsDelete(w http.ResponseWriter, r *http.Request) {
+  if !adminAuthorized(w, r) {
+    renderJSONError(w, http.StatusUnauthorized, "admin login required")
+    return
+  }
+
+  uid, err := strconv.Atoi(mux.Vars(r)["uid"])
+  if err != nil {
+    renderJSONError(w, http.StatusBadRequest, "stopword id must be a number")
+    return
+  }
+  deleted, err := e.store.DeleteStopword(uid)
+  if err != nil {
+    renderJSONError(w, http.StatusInternalServerError, err.Error())
+    return
+  }
+  if deleted == 0 {
+    renderJSONError(w, http.StatusNotFound, fmt.Sprintf("No stopword %d", uid))
+    return
+  }
+  w.WriteHeader(http.StatusNoContent)
+}
\ No newline at end of file
@@ -5,6 +5,7 @@ import (
   "net/http"
   "time"
   "strconv"
+  "strings"
   "html/template"
   "github.com/gorilla/mux"
 )
@@ -14,23 +15,23 @@ func renderTemplate(w http.ResponseWriter, tmpl string, content map[string]inter
   t, err := template.ParseFiles("views/" + tmpl + ".html")
 
   if err != nil {
-    fmt.Println("%s", err)
+    fmt.Println("Error:", err)
     http.Error(w, err.Error(), http.StatusInternalServerError)
     return
   }
 
   err = t.Execute(w, content)
   if err != nil {
-    fmt.Println("%s", err)
+    fmt.Println("Error:", err)
     http.Error(w, err.Error(), http.StatusInternalServerError)
   }
 }
 
 // Main home page
-func Index(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) Index(w http.ResponseWriter, r *http.Request) {
   w.Header().Add("Access-Control-Allow-Headers", "Content-Type, api_key, Authorization")
 
-  locations, err := BuildLocationsList()
+  locations, err := BuildLocationsList(e.store)
 
   content := make(map[string]interface{})
   content["Title"] = "Welcome to the Udadisi Engine"
@@ -48,14 +49,14 @@ func Index(w http.ResponseWriter, r *http.Request) {
 }
 
 // Diagonistic web pages
-func WebStats(w http.ResponseWriter, r *http.Request) {
-  locations, err := BuildLocationsList()
+func (e *Engine) WebStats(w http.ResponseWriter, r *http.Request) {
+  locations, err := BuildLocationsList(e.store)
 
   postsCount := map[string]int {}
   lastPosted := map[string]time.Time {}
   for _, location := range locations {
-    postsCount[location.Name], err = DatabasePostsCount(location.Name)
-    lastPosted[location.Name], err = DatabaseLastMined(location.Name)
+    postsCount[location.Name], err = e.store.PostsCount(location.Name)
+    lastPosted[location.Name], err = e.store.LastMined(location.Name)
   }
 
   content := make(map[string]interface{})
@@ -71,7 +72,7 @@ func WebStats(w http.ResponseWriter, r *http.Request) {
   renderTemplate(w, "stats", content)
 }
 
-func WebTrendsRouteIndex(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) WebTrendsRouteIndex(w http.ResponseWriter, r *http.Request) {
   vars := mux.Vars(r)
   location := vars["location"]
   source := r.URL.Query().Get("source")
@@ -95,7 +96,11 @@ func WebTrendsRouteIndex(w http.ResponseWriter, r *http.Request) {
   if interval < 1 {
     interval = 2
   }
-  sortedCounts, err := WordCountRootCollection(location, source, fromParam, toParam, int(interval), int(limit))
+  ngram, _ := ParseNgramRange(r.URL.Query().Get("ngram"))
+  stemmer, _ := StemmerFor(r.URL.Query().Get("stem"))
+  lang := strings.ToLower(r.URL.Query().Get("lang"))
+  algorithm := r.URL.Query().Get("algorithm")
+  sortedCounts, err := WordCountRootCollection(e.store, location, source, fromParam, toParam, int(interval), int(limit), ngram, stemmer, lang, algorithm)
 
   content := make(map[string]interface{})
   if err != nil {
@@ -112,7 +117,7 @@ func WebTrendsRouteIndex(w http.ResponseWriter, r *http.Request) {
   renderTemplate(w, "termsindex", content)
 }
 
-func WebTrendsIndex(w http.ResponseWriter, r *http.Request) {
+func (e *Engine) WebTrendsIndex(w http.ResponseWriter, r *http.Request) {
   vars := mux.Vars(r)
   location := vars["location"]
   source := r.URL.Query().Get("source")
@@ -134,7 +139,11 @@ func WebTrendsIndex(w http.ResponseWriter, r *http.Request) {
     interval = 2
   }
 
-  termPackage := TrendsCollection(source, location, term, fromParam, toParam, interval, 1.0, 0.0)
+  termPackage, err := TrendsCollection(e.store, source, location, term, fromParam, toParam, interval, 1.0, 0.0, 0, 0, "", 0)
+  if err != nil {
+    http.Error(w, err.Error(), collectionErrorStatus(err))
+    return
+  }
 
   content := make(map[string]interface{})
   content["Location"] = location
@@ -144,4 +153,95 @@ func WebTrendsIndex(w http.ResponseWriter, r *http.Request) {
   content["TermPackage"] = termPackage
 
   renderTemplate(w, "term", content)
+}
+
+// Bootstrap classes telling apart the terms on a chart
+var chartColours = []string {
+  "progress-bar",
+  "progress-bar progress-bar-success",
+  "progress-bar progress-bar-warning",
+  "progress-bar progress-bar-danger",
+  "progress-bar progress-bar-info",
+  "progress-bar progress-bar-striped",
+  "progress-bar progress-bar-success progress-bar-striped",
+  "progress-bar progress-bar-warning progress-bar-striped",
+  "progress-bar progress-bar-danger progress-bar-striped",
+  "progress-bar progress-bar-info progress-bar-striped",
+}
+
+// A term's count in one bucket of a chart, with its share as a percentage
+type chartCell struct {
+  Term string
+  Count int
+  Percent float64
+  Colour string
+}
+
+type chartRow struct {
+  Start time.Time
+  Total int
+  Cells []chartCell
+}
+
+// Charts the terms listed, comma separated, in the terms parameter, with
+// each bucket's share of voice as a stacked bar
+func (e *Engine) WebTermChart(w http.ResponseWriter, r *http.Request) {
+  vars := mux.Vars(r)
+  location := vars["location"]
+  source := r.URL.Query().Get("source")
+  termsParam := r.URL.Query().Get("terms")
+  fromParam := r.URL.Query().Get("from")
+  toParam := r.URL.Query().Get("to")
+  intervalParam := r.URL.Query().Get("interval")
+  intervalConv, _ := strconv.ParseInt(intervalParam, 10, 0)
+  interval := int(intervalConv)
+  t := time.Now()
+  if fromParam == "" {
+    from := t.Add(-24 * time.Hour)
+    fromParam = from.Format("200601021504")
+  }
+  if toParam == "" {
+    toParam = t.Format("200601021504")
+  }
+  if interval < 1 {
+    interval = DefaultChartInterval
+  }
+
+  content := make(map[string]interface{})
+  content["Title"] = "Compare Terms"
+  content["Location"] = location
+  content["Terms"] = termsParam
+  content["FromParam"] = fromParam
+  content["ToParam"] = toParam
+  content["Interval"] = interval
+
+  if termsParam != "" {
+    chart, err := TermChartCollection(e.store, source, location, ParseTermList(termsParam), fromParam, toParam, interval)
+    if err != nil {
+      content["Error"] = err
+    } else {
+      colours := map[string]string {}
+      for i, term := range chart.Terms {
+        colours[term.Term] = chartColours[i % len(chartColours)]
+      }
+      rows := []chartRow {}
+      for i, start := range chart.Buckets {
+        row := chartRow {Start: start, Total: chart.Totals[i]}
+        for _, term := range chart.Terms {
+          row.Cells = append(row.Cells, chartCell {
+            Term: term.Term,
+            Count: term.Series[i],
+            Percent: 100 * term.Share[i],
+            Colour: colours[term.Term],
+          })
+        }
+        rows = append(rows, row)
+      }
+      content["Chart"] = chart
+      content["Colours"] = colours
+      content["Rows"] = rows
+    }
+  }
+
+  renderTemplate(w, "compare", content)
 }
\ No newline at end of file
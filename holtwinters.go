@@ -0,0 +1,135 @@
+package main
+
+import (
+  "math"
+)
+
+// Additive Holt-Winters exponential smoothing: a level, a trend and, when
+// the series covers at least two seasons, a seasonal component, each
+// updated as the series is read. The smoothing parameters are chosen to
+// minimise the one step ahead errors over the series. Without a season this
+// is Holt's linear trend method.
+type HoltWintersForecaster struct{}
+
+// The smoothing parameter values tried when fitting
+var holtWintersGrid = []float64{0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+
+type holtWintersFit struct {
+  alpha, beta, gamma float64
+  level, trend float64
+  seasonal []float64
+  residuals []float64
+  sse float64
+}
+
+func (HoltWintersForecaster) Forecast(series []float64, season int, horizon int) ([]float64, []float64) {
+  if season < 2 || len(series) < 2 * season {
+    season = 0
+  }
+  if len(series) < 2 {
+    return flatForecast(series, horizon)
+  }
+
+  gammas := []float64{0}
+  if season > 0 {
+    gammas = holtWintersGrid
+  }
+  var best *holtWintersFit
+  for _, alpha := range holtWintersGrid {
+    for _, beta := range holtWintersGrid {
+      for _, gamma := range gammas {
+        fit := fitHoltWinters(series, season, alpha, beta, gamma)
+        if best == nil || fit.sse < best.sse {
+          best = fit
+        }
+      }
+    }
+  }
+
+  deviation := residualDeviation(best.residuals, 3)
+  predictions := make([]float64, horizon)
+  deviations := make([]float64, horizon)
+  variance := 0.0
+  for h := 1; h <= horizon; h++ {
+    prediction := best.level + float64(h) * best.trend
+    if season > 0 {
+      prediction += best.seasonal[(len(series) + h - 1) % season]
+    }
+    predictions[h - 1] = prediction
+
+    // The variance of the h step ahead error grows by the square of the
+    // weight the (h - 1)th step's error carries into it
+    if h > 1 {
+      weight := best.alpha * (1 + float64(h - 1) * best.beta)
+      if season > 0 && (h - 1) % season == 0 {
+        weight += best.gamma
+      }
+      variance += weight * weight
+    }
+    deviations[h - 1] = deviation * math.Sqrt(1 + variance)
+  }
+  return predictions, deviations
+}
+
+func fitHoltWinters(series []float64, season int, alpha float64, beta float64, gamma float64) *holtWintersFit {
+  fit := &holtWintersFit{alpha: alpha, beta: beta, gamma: gamma}
+
+  // Start from the trend between the means of the first two seasons, the
+  // level that trend reaches by the end of the first season and each
+  // interval's difference from it, or from the first two values without a
+  // season
+  start := 1
+  if season > 0 {
+    first, second := 0.0, 0.0
+    for i := 0; i < season; i++ {
+      first += series[i]
+      second += series[season + i]
+    }
+    first, second = first / float64(season), second / float64(season)
+    fit.trend = (second - first) / float64(season)
+    middle := float64(season - 1) / 2
+    fit.level = first + fit.trend * middle
+    fit.seasonal = make([]float64, season)
+    for i := 0; i < season; i++ {
+      fit.seasonal[i] = series[i] - (first + fit.trend * (float64(i) - middle))
+    }
+    start = season
+  } else {
+    fit.level = series[0]
+    fit.trend = series[1] - series[0]
+  }
+
+  for t := start; t < len(series); t++ {
+    seasonal := 0.0
+    if season > 0 {
+      seasonal = fit.seasonal[t % season]
+    }
+    residual := series[t] - (fit.level + fit.trend + seasonal)
+    fit.residuals = append(fit.residuals, residual)
+    fit.sse += residual * residual
+
+    level := alpha * (series[t] - seasonal) + (1 - alpha) * (fit.level + fit.trend)
+    fit.trend = beta * (level - fit.level) + (1 - beta) * fit.trend
+    if season > 0 {
+      fit.seasonal[t % season] = gamma * (series[t] - level) + (1 - gamma) * seasonal
+    }
+    fit.level = level
+  }
+  return fit
+}
+
+// Repeats the last value of a series too short to fit, with its error
+// scaled as a count's would be.
+func flatForecast(series []float64, horizon int) ([]float64, []float64) {
+  last := 0.0
+  if len(series) > 0 {
+    last = series[len(series) - 1]
+  }
+  predictions := make([]float64, horizon)
+  deviations := make([]float64, horizon)
+  for h := range predictions {
+    predictions[h] = last
+    deviations[h] = math.Sqrt(math.Max(last, 1) * float64(h + 1))
+  }
+  return predictions, deviations
+}
\ No newline at end of file
@@ -0,0 +1,229 @@
+package main
+
+import (
+  "crypto/rand"
+  "database/sql"
+  "encoding/hex"
+  "fmt"
+  "os"
+  "strconv"
+  "time"
+)
+
+// Batch statuses reported by GET /v1/minerpost/{batchId}
+const (
+  BatchQueued = "queued"
+  BatchProcessing = "processing"
+  BatchDone = "done"
+  BatchFailed = "failed"
+)
+
+const (
+  DefaultIngestWorkers = 4
+  IngestQueueSize = 100
+  // How long the status of a batch is kept after it is received, and how
+  // often older statuses are looked for
+  IngestStatusRetention = 7 * 24 * time.Hour
+  IngestStatusSweep = time.Hour
+)
+
+// The progress of a batch of posts sent by a miner.
+type BatchStatus struct {
+  BatchId string `json:"batch_id"`
+  MinerId int `json:"miner_id"`
+  Status string `json:"status"`
+  Received time.Time `json:"received"`
+  Completed *time.Time `json:"completed,omitempty"`
+  Posts int `json:"posts"`
+  Accepted int `json:"accepted"`
+  Duplicate int `json:"duplicate"`
+  Failed int `json:"failed"`
+  Rejected int `json:"rejected"`
+  Error string `json:"error,omitempty"`
+}
+
+type ingestJob struct {
+  id string
+  miner Miner
+  posts []MinerPostJSON
+}
+
+// An Ingester stores the batches posted by miners in the background. Batches
+// are queued by Enqueue and written by a pool of workers, each batch in its
+// own transaction, and their progress is kept in the store for Status to
+// report, so it survives a restart.
+type Ingester struct {
+  store Store
+  queue chan ingestJob
+}
+
+// Creates an Ingester for store and starts its workers, and the sweep that
+// forgets old batches.
+func NewIngester(store Store, workers int, queueSize int) *Ingester {
+  ingester := &Ingester{
+    store: store,
+    queue: make(chan ingestJob, queueSize),
+  }
+  for i := 0; i < workers; i++ {
+    go ingester.work()
+  }
+  go ingester.sweep(IngestStatusSweep)
+  return ingester
+}
+
+// Returns the number of ingest workers set by INGEST_WORKERS.
+func IngestWorkers() int {
+  workers, err := strconv.Atoi(os.Getenv("INGEST_WORKERS"))
+  if err != nil || workers < 1 {
+    return DefaultIngestWorkers
+  }
+  return workers
+}
+
+// Queues a miner's valid posts for storing and returns the new batch's
+// status, counting the rejected posts that failed validation. Fails without
+// queueing anything when the queue is full.
+func (i *Ingester) Enqueue(miner Miner, posts []MinerPostJSON, rejected int) (status BatchStatus, err error) {
+  id, err := newBatchId()
+  if err != nil {
+    return
+  }
+
+  status = BatchStatus{
+    BatchId: id,
+    MinerId: miner.Uid,
+    Status: BatchQueued,
+    Received: time.Now().UTC(),
+    Posts: len(posts) + rejected,
+    Rejected: rejected,
+  }
+  if err = i.store.InsertBatch(status); err != nil {
+    return
+  }
+
+  select {
+  case i.queue <- ingestJob{id: id, miner: miner, posts: posts}:
+  default:
+    i.finish(id, 0, 0, fmt.Errorf("Ingest queue is full"))
+    return status, fmt.Errorf("Ingest queue is full, try again later")
+  }
+  return status, nil
+}
+
+// Returns the status of one of a miner's batches, or sql.ErrNoRows if the
+// miner sent no such batch or its status is no longer retained.
+func (i *Ingester) Status(minerId int, id string) (BatchStatus, error) {
+  batch, err := i.store.Batch(id)
+  if err != nil {
+    return batch, err
+  }
+  if batch.MinerId != minerId {
+    return BatchStatus{}, sql.ErrNoRows
+  }
+  return batch, nil
+}
+
+func (i *Ingester) work() {
+  for job := range i.queue {
+    i.process(job)
+  }
+}
+
+func (i *Ingester) process(job ingestJob) {
+  i.update(job.id, func(batch *BatchStatus) {
+    batch.Status = BatchProcessing
+  })
+
+  defer func() {
+    if r := recover(); r != nil {
+      i.finish(job.id, 0, 0, fmt.Errorf("%v", r))
+    }
+  }()
+
+  posts := []PostWithTerms {}
+  for _, post := range job.posts {
+    stored := PostWithTerms {
+      Post: Post {
+        Source: job.miner.Source,
+        Location: job.miner.Location,
+        SourceURI: post.Url,
+        Posted: post.Datetime.Time,
+        Mined: post.MinedAt.Time,
+        Lang: post.Lang,
+      },
+      Terms: Terms {},
+    }
+    for term, count := range post.Terms {
+      stored.Terms = append(stored.Terms, Term {Term: term, WordCount: count, Ngram: NgramOf(term)})
+    }
+    posts = append(posts, stored)
+  }
+
+  uids, err := i.store.InsertPosts(posts)
+  if err != nil {
+    fmt.Println("Error:", err)
+    i.finish(job.id, 0, 0, err)
+    return
+  }
+
+  accepted := 0
+  for _, uid := range uids {
+    if uid != 0 {
+      accepted++
+    }
+  }
+  i.finish(job.id, accepted, len(uids) - accepted, nil)
+}
+
+// Records the outcome of a batch.
+func (i *Ingester) finish(id string, accepted int, duplicate int, err error) {
+  completed := time.Now().UTC()
+  i.update(id, func(batch *BatchStatus) {
+    batch.Completed = &completed
+    batch.Accepted = accepted
+    batch.Duplicate = duplicate
+    batch.Failed = batch.Posts - batch.Rejected - accepted - duplicate
+    batch.Status = BatchDone
+    if err != nil {
+      batch.Status = BatchFailed
+      batch.Error = err.Error()
+    }
+  })
+}
+
+// Forgets old batches on start up and then every interval, rather than
+// after each batch is stored.
+func (i *Ingester) sweep(interval time.Duration) {
+  i.forgetBatches(time.Now().UTC())
+  for now := range time.Tick(interval) {
+    i.forgetBatches(now.UTC())
+  }
+}
+
+// Forgets the batches received longer than IngestStatusRetention before now.
+func (i *Ingester) forgetBatches(now time.Time) {
+  if _, err := i.store.DeleteBatches(now.Add(-IngestStatusRetention)); err != nil {
+    fmt.Println("Error:", err)
+  }
+}
+
+// Applies change to a batch's stored status. Each batch is only changed by
+// the worker storing it.
+func (i *Ingester) update(id string, change func(batch *BatchStatus)) {
+  batch, err := i.store.Batch(id)
+  if err == nil {
+    change(&batch)
+    err = i.store.UpdateBatch(batch)
+  }
+  if err != nil {
+    fmt.Println("Error:", err)
+  }
+}
+
+func newBatchId() (string, error) {
+  b := make([]byte, 16)
+  if _, err := rand.Read(b); err != nil {
+    return "", err
+  }
+  return hex.EncodeToString(b), nil
+}
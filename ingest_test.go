@@ -0,0 +1,120 @@
+package main
+
+import (
+  "database/sql"
+  "testing"
+  "time"
+)
+
+// Waits for a batch to be done or failed and returns its status.
+func waitForBatch(t *testing.T, ingester *Ingester, minerId int, id string) BatchStatus {
+  deadline := time.Now().Add(5 * time.Second)
+  for time.Now().Before(deadline) {
+    batch, err := ingester.Status(minerId, id)
+    if err != nil {
+      t.Fatalf("Status: %v", err)
+    }
+    if batch.Status == BatchDone || batch.Status == BatchFailed {
+      return batch
+    }
+    time.Sleep(5 * time.Millisecond)
+  }
+  t.Fatalf("batch %s still not done", id)
+  return BatchStatus {}
+}
+
+func minerPost(url string, posted time.Time, terms map[string]int) MinerPostJSON {
+  return MinerPostJSON {
+    Terms: terms,
+    Url: url,
+    Lang: "en",
+    Datetime: myTime{posted},
+    MinedAt: myTime{posted},
+  }
+}
+
+func TestIngesterCountsDuplicatesAndRejected(t *testing.T) {
+  store := NewMemoryStore()
+  uid, _ := store.InsertMiner(Miner {Location: "nairobi", Source: "twitter"})
+  miner, _ := store.Miner(uid)
+  ingester := NewIngester(store, 1, 10)
+
+  posts := []MinerPostJSON {
+    minerPost("http://t/1", testNow, map[string]int {"jobs": 1}),
+    minerPost("http://t/2", testNow, map[string]int {"jobs": 2}),
+  }
+  status, err := ingester.Enqueue(miner, posts, 1)
+  if err != nil {
+    t.Fatalf("Enqueue: %v", err)
+  }
+  if status.Status != BatchQueued || status.Posts != 3 || status.Rejected != 1 {
+    t.Errorf("queued status = %+v, want 3 posts with 1 rejected", status)
+  }
+  batch := waitForBatch(t, ingester, miner.Uid, status.BatchId)
+  if batch.Status != BatchDone || batch.Accepted != 2 || batch.Duplicate != 0 || batch.Failed != 0 || batch.Completed == nil {
+    t.Errorf("first batch = %+v, want 2 accepted", batch)
+  }
+
+  // Sending a post again counts it as a duplicate rather than storing it
+  status, _ = ingester.Enqueue(miner, posts[1:], 0)
+  batch = waitForBatch(t, ingester, miner.Uid, status.BatchId)
+  if batch.Status != BatchDone || batch.Accepted != 0 || batch.Duplicate != 1 {
+    t.Errorf("second batch = %+v, want 1 duplicate", batch)
+  }
+  if count, _ := store.PostsCount("nairobi"); count != 2 {
+    t.Errorf("PostsCount = %d, want 2", count)
+  }
+}
+
+func TestIngesterStatusIsTheSendingMiners(t *testing.T) {
+  store := NewMemoryStore()
+  uid, _ := store.InsertMiner(Miner {Location: "nairobi", Source: "twitter"})
+  other, _ := store.InsertMiner(Miner {Location: "lagos", Source: "twitter"})
+  miner, _ := store.Miner(uid)
+  ingester := NewIngester(store, 1, 10)
+
+  status, err := ingester.Enqueue(miner, []MinerPostJSON {minerPost("http://t/1", testNow, map[string]int {"jobs": 1})}, 0)
+  if err != nil {
+    t.Fatalf("Enqueue: %v", err)
+  }
+  waitForBatch(t, ingester, miner.Uid, status.BatchId)
+
+  if _, err := ingester.Status(other, status.BatchId); err != sql.ErrNoRows {
+    t.Errorf("Status for another miner = %v, want sql.ErrNoRows", err)
+  }
+  if _, err := ingester.Status(miner.Uid, "missing"); err != sql.ErrNoRows {
+    t.Errorf("Status of an unknown batch = %v, want sql.ErrNoRows", err)
+  }
+
+  // Statuses are kept in the store until the sweep finds them older than
+  // the retention
+  ingester.forgetBatches(time.Now())
+  if _, err := ingester.Status(miner.Uid, status.BatchId); err != nil {
+    t.Errorf("Status of a recent batch after a sweep = %v", err)
+  }
+  ingester.forgetBatches(time.Now().Add(IngestStatusRetention + time.Minute))
+  if _, err := ingester.Status(miner.Uid, status.BatchId); err != sql.ErrNoRows {
+    t.Errorf("Status of a batch past the retention = %v, want sql.ErrNoRows", err)
+  }
+}
+
+func TestIngesterFailsWhenQueueIsFull(t *testing.T) {
+  store := NewMemoryStore()
+  uid, _ := store.InsertMiner(Miner {Location: "nairobi", Source: "twitter"})
+  miner, _ := store.Miner(uid)
+  // No workers, so nothing leaves the queue
+  ingester := NewIngester(store, 0, 1)
+
+  posts := []MinerPostJSON {minerPost("http://t/1", testNow, map[string]int {"jobs": 1})}
+  if _, err := ingester.Enqueue(miner, posts, 0); err != nil {
+    t.Fatalf("Enqueue: %v", err)
+  }
+  status, err := ingester.Enqueue(miner, posts, 0)
+  if err == nil {
+    t.Fatalf("Enqueue into a full queue succeeded")
+  }
+  batch, err := ingester.Status(miner.Uid, status.BatchId)
+  if err != nil || batch.Status != BatchFailed || batch.Failed != 1 {
+    t.Errorf("full queue batch = %+v, %v, want failed", batch, err)
+  }
+}
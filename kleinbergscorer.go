@@ -0,0 +1,81 @@
+package main
+
+import (
+  "math"
+)
+
+// Kleinberg's two state burst automaton (Kleinberg 2002) for counts. Each
+// interval is emitted by a base state at the series' mean rate or a burst
+// state at Scaling times that rate, and moving into the burst state costs
+// Gamma times the log of the number of intervals. The cheapest sequence of
+// states is found with the Viterbi algorithm, and the score is the weight
+// of the burst the last interval belongs to: how much better the burst
+// state explains the counts of that burst than the base state does. It is
+// 0 when the last interval is not in a burst.
+type KleinbergScorer struct {
+  Scaling float64
+  Gamma float64
+}
+
+func (scorer KleinbergScorer) Score(series []int) Score {
+  if len(series) == 0 {
+    return Score {}
+  }
+
+  mean := 0.0
+  for _, count := range series {
+    mean += float64(count)
+  }
+  mean = mean / float64(len(series))
+  if mean == 0 {
+    return Score {}
+  }
+
+  rates := [2]float64{mean, mean * scorer.Scaling}
+  cost := func(state int, count int) float64 {
+    return -poissonLogProbability(count, rates[state])
+  }
+  transition := scorer.Gamma * math.Log(math.Max(float64(len(series)), 2))
+
+  // costs[state] is the cheapest cost of the series so far ending in state,
+  // from[t][state] the state before it at interval t
+  costs := [2]float64{cost(0, series[0]), transition + cost(1, series[0])}
+  from := make([][2]int, len(series))
+  for t := 1; t < len(series); t++ {
+    next := [2]float64 {}
+    for state := 0; state < 2; state++ {
+      stay := costs[state]
+      move := costs[1 - state]
+      if state == 1 {
+        move += transition
+      }
+      if stay <= move {
+        next[state] = stay
+        from[t][state] = state
+      } else {
+        next[state] = move
+        from[t][state] = 1 - state
+      }
+      next[state] += cost(state, series[t])
+    }
+    costs = next
+  }
+
+  state := 0
+  if costs[1] < costs[0] {
+    state = 1
+  }
+  weight := 0.0
+  for t := len(series) - 1; t >= 0 && state == 1; t-- {
+    weight += cost(0, series[t]) - cost(1, series[t])
+    state = from[t][state]
+  }
+  return Score{Value: weight, Baseline: mean}
+}
+
+// The log of the probability of count under a Poisson distribution with
+// the given rate.
+func poissonLogProbability(count int, rate float64) float64 {
+  logFactorial, _ := math.Lgamma(float64(count) + 1)
+  return float64(count) * math.Log(rate) - rate - logFactorial
+}
\ No newline at end of file
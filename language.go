@@ -0,0 +1,95 @@
+package main
+
+import (
+  "sort"
+  "strings"
+)
+
+// Built-in stopword lists, by ISO 639-1 language code. They are removed
+// from post text in the post's language at ingest, and from trends
+// filtered by language at query time.
+var LanguageStopwords = map[string][]string{
+  "en": strings.Fields(`a about above after again against all am an and any are aren't as at be
+    because been before being below between both but by can can't cannot could couldn't did didn't
+    do does doesn't doing don't down during each few for from further get got had hadn't has hasn't
+    have haven't having he he'd he'll he's her here here's hers herself him himself his how how's i
+    i'd i'll i'm i've if in into is isn't it it's its itself just let's me more most mustn't my
+    myself no nor not now of off on once only or other ought our ours ourselves out over own rt same
+    shan't she she'd she'll she's should shouldn't so some such than that that's the their theirs
+    them themselves then there there's these they they'd they'll they're they've this those through
+    to too under until up us very via was wasn't we we'd we'll we're we've were weren't what what's
+    when when's where where's which while who who's whom why why's will with won't would wouldn't
+    you you'd you'll you're you've your yours yourself yourselves`),
+  "sw": strings.Fields(`au baada basi bila cha chini hadi hao hapa hapo hata hicho hii hilo hivyo
+    hiyo hizo huku huo huyo ili juu kabla katika kama kila kuhusu kutoka kuwa kwa kwamba kwenye la
+    lakini lini mimi mwa na nani ndani ndiyo nini ninyi nje ni pale pamoja pia sana sasa sisi tena
+    tu vile vya wa wake wao wapi wewe wote ya yake yao yeye za zaidi zake`),
+  "es": strings.Fields(`a al algo algunas algunos ante antes como con contra cual cuando de del
+    desde donde durante e el ella ellas ellos en entre era es esa esas ese eso esos esta estaba
+    estado estamos estan estar estas este esto estos estoy fue fueron ha habia han hasta hay la las
+    le les lo los mas me mi mis mucho muy nada ni no nos nosotros o os otra otras otro otros para
+    pero poco por porque que quien se sea ser si sido siempre sin sobre su sus tambien tanto te
+    tiene tienen todo todos tu tus un una uno unos y ya yo él más qué también está están así sí
+    sólo`),
+}
+
+// Returns the languages that have built-in stopword lists, sorted.
+func StopwordLanguages() []string {
+  languages := []string {}
+  for language := range LanguageStopwords {
+    languages = append(languages, language)
+  }
+  sort.Strings(languages)
+  return languages
+}
+
+// Returns the built-in stopwords for a language, or none when language is
+// "" or has no list. The lists are never merged, as a stopword of one
+// language is often an ordinary word of another, such as "era" or "pale".
+func BuiltinStopwords(language string) []string {
+  return LanguageStopwords[language]
+}
+
+// Guesses the language of a post's words from how many of them are
+// stopwords of each language. Returns "" when no language has more hits
+// than the rest.
+func DetectLanguage(words []string) string {
+  hits := map[string]int {}
+  for _, language := range StopwordLanguages() {
+    stopwords := map[string]bool {}
+    for _, word := range LanguageStopwords[language] {
+      stopwords[word] = true
+    }
+    for _, word := range words {
+      if stopwords[strings.ToLower(word)] {
+        hits[language]++
+      }
+    }
+  }
+
+  best, bestHits, tied := "", 0, false
+  for language, count := range hits {
+    if count > bestHits {
+      best, bestHits, tied = language, count, false
+    } else if count == bestHits {
+      tied = true
+    }
+  }
+  if tied {
+    return ""
+  }
+  return best
+}
+
+// Splits text into lowercase words with punctuation trimmed, for
+// DetectLanguage.
+func LanguageWords(text string) (words []string) {
+  words = []string {}
+  for _, field := range strings.Fields(text) {
+    word := strings.ToLower(strings.TrimFunc(field, isPunctuation))
+    if word != "" {
+      words = append(words, word)
+    }
+  }
+  return
+}
\ No newline at end of file
@@ -0,0 +1,62 @@
+package main
+
+import (
+  "math"
+)
+
+// A least squares linear trend, plus an offset for each interval of the
+// season when the series covers at least two seasons.
+type LinearForecaster struct{}
+
+func (LinearForecaster) Forecast(series []float64, season int, horizon int) ([]float64, []float64) {
+  if season < 2 || len(series) < 2 * season {
+    season = 0
+  }
+  if len(series) < 3 {
+    return flatForecast(series, horizon)
+  }
+
+  // Each interval of the season is its own group, or the whole series is
+  // one group without a season. The slope is fitted within the groups so
+  // their offsets don't absorb the trend.
+  groups := season
+  if groups == 0 {
+    groups = 1
+  }
+  counts := make([]float64, groups)
+  meanT := make([]float64, groups)
+  meanY := make([]float64, groups)
+  for t, value := range series {
+    counts[t % groups]++
+    meanT[t % groups] += float64(t)
+    meanY[t % groups] += value
+  }
+  for i := range counts {
+    meanT[i] = meanT[i] / counts[i]
+    meanY[i] = meanY[i] / counts[i]
+  }
+
+  sxx, sxy := 0.0, 0.0
+  for t, value := range series {
+    dt := float64(t) - meanT[t % groups]
+    sxx += dt * dt
+    sxy += dt * (value - meanY[t % groups])
+  }
+  slope := sxy / sxx
+
+  residuals := make([]float64, len(series))
+  for t, value := range series {
+    residuals[t] = value - (meanY[t % groups] + slope * (float64(t) - meanT[t % groups]))
+  }
+  deviation := residualDeviation(residuals, 1 + groups)
+
+  predictions := make([]float64, horizon)
+  deviations := make([]float64, horizon)
+  for h := range predictions {
+    t := len(series) + h
+    dt := float64(t) - meanT[t % groups]
+    predictions[h] = meanY[t % groups] + slope * dt
+    deviations[h] = deviation * math.Sqrt(1 + 1 / counts[t % groups] + dt * dt / sxx)
+  }
+  return predictions, deviations
+}
\ No newline at end of file
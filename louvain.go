@@ -0,0 +1,144 @@
+package main
+
+import (
+  "sort"
+)
+
+// An undirected weighted graph of nodes 0 to n - 1. Each edge is held in
+// both directions, and a self loop holds the weight of the edges folded
+// into its node twice over, so a node's degree is always its row's sum.
+type weightedGraph []map[int]float64
+
+func newWeightedGraph(n int) weightedGraph {
+  g := make(weightedGraph, n)
+  for i := range g {
+    g[i] = map[int]float64 {}
+  }
+  return g
+}
+
+func (g weightedGraph) addEdge(i int, j int, weight float64) {
+  g[i][j] += weight
+  if i != j {
+    g[j][i] += weight
+  }
+}
+
+func (g weightedGraph) degree(i int) float64 {
+  degree := 0.0
+  for _, weight := range g[i] {
+    degree += weight
+  }
+  return degree
+}
+
+// The neighbours of node i in order, so communities come out the same
+// every time for the same graph.
+func (g weightedGraph) neighbours(i int) []int {
+  neighbours := make([]int, 0, len(g[i]))
+  for j := range g[i] {
+    neighbours = append(neighbours, j)
+  }
+  sort.Ints(neighbours)
+  return neighbours
+}
+
+// Splits a graph into communities by the Louvain method, greedily moving
+// nodes to the neighbouring community that most raises modularity and then
+// folding each community into a node, until no move helps. Returns the
+// community of each node, numbered from 0 in order of their first node.
+// Nodes without edges are left in communities of their own.
+func louvain(g weightedGraph) []int {
+  community := make([]int, len(g))
+  for i := range community {
+    community[i] = i
+  }
+
+  for {
+    moved := louvainMoveNodes(g)
+    groups := renumberCommunities(moved)
+    if groups == len(g) {
+      break
+    }
+    for i := range community {
+      community[i] = moved[community[i]]
+    }
+
+    folded := newWeightedGraph(groups)
+    for i := range g {
+      for j, weight := range g[i] {
+        folded[moved[i]][moved[j]] += weight
+      }
+    }
+    g = folded
+  }
+
+  renumberCommunities(community)
+  return community
+}
+
+// The first phase of the Louvain method: starting with every node in its
+// own community, moves nodes between communities until modularity stops
+// rising.
+func louvainMoveNodes(g weightedGraph) []int {
+  community := make([]int, len(g))
+  degrees := make([]float64, len(g))
+  totals := make([]float64, len(g))
+  total := 0.0
+  for i := range g {
+    community[i] = i
+    degrees[i] = g.degree(i)
+    totals[i] = degrees[i]
+    total += degrees[i]
+  }
+  if total == 0 {
+    return community
+  }
+
+  for moved := true; moved; {
+    moved = false
+    for i := range g {
+      // Weight of the edges from i to each neighbouring community
+      links := map[int]float64 {}
+      order := []int {}
+      for _, j := range g.neighbours(i) {
+        if j == i {
+          continue
+        }
+        if _, ok := links[community[j]]; !ok {
+          order = append(order, community[j])
+        }
+        links[community[j]] += g[i][j]
+      }
+
+      current := community[i]
+      totals[current] -= degrees[i]
+      best, bestGain := current, links[current] - totals[current] * degrees[i] / total
+      for _, c := range order {
+        gain := links[c] - totals[c] * degrees[i] / total
+        if gain > bestGain + 1e-12 {
+          best, bestGain = c, gain
+        }
+      }
+      totals[best] += degrees[i]
+      if best != current {
+        community[i] = best
+        moved = true
+      }
+    }
+  }
+  return community
+}
+
+// Renumbers communities from 0 in order of their first member, in place,
+// and returns how many there are.
+func renumberCommunities(community []int) int {
+  numbers := map[int]int {}
+  for i, c := range community {
+    if _, ok := numbers[c]; !ok {
+      numbers[c] = len(numbers)
+    }
+    community[i] = numbers[c]
+  }
+  return len(numbers)
+}
\ No newline at end of file
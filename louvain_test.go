@@ -0,0 +1,57 @@
+package main
+
+import (
+  "reflect"
+  "testing"
+)
+
+func TestLouvainSplitsCliques(t *testing.T) {
+  // Two cliques of four joined by one light edge, and a node on its own
+  g := newWeightedGraph(9)
+  for _, clique := range [][]int {{0, 1, 2, 3}, {4, 5, 6, 7}} {
+    for i := range clique {
+      for j := i + 1; j < len(clique); j++ {
+        g.addEdge(clique[i], clique[j], 3)
+      }
+    }
+  }
+  g.addEdge(3, 4, 1)
+
+  want := []int {0, 0, 0, 0, 1, 1, 1, 1, 2}
+  if got := louvain(g); !reflect.DeepEqual(got, want) {
+    t.Errorf("louvain = %v, want %v", got, want)
+  }
+}
+
+func TestLouvainNumbersCommunitiesInOrder(t *testing.T) {
+  // The communities' first members are interleaved, and are still
+  // numbered from 0 in order of them
+  g := newWeightedGraph(6)
+  g.addEdge(0, 2, 5)
+  g.addEdge(2, 4, 5)
+  g.addEdge(0, 4, 5)
+  g.addEdge(1, 3, 5)
+  g.addEdge(3, 5, 5)
+  g.addEdge(1, 5, 5)
+
+  want := []int {0, 1, 0, 1, 0, 1}
+  if got := louvain(g); !reflect.DeepEqual(got, want) {
+    t.Errorf("louvain = %v, want %v", got, want)
+  }
+}
+
+func TestLouvainWithoutEdges(t *testing.T) {
+  if got := louvain(newWeightedGraph(3)); !reflect.DeepEqual(got, []int {0, 1, 2}) {
+    t.Errorf("louvain = %v, want each node on its own", got)
+  }
+  if got := louvain(newWeightedGraph(0)); len(got) != 0 {
+    t.Errorf("louvain of no nodes = %v", got)
+  }
+}
+
+func TestRenumberCommunities(t *testing.T) {
+  community := []int {7, 3, 7, 9, 3}
+  if n := renumberCommunities(community); n != 3 || !reflect.DeepEqual(community, []int {0, 1, 0, 2, 1}) {
+    t.Errorf("renumberCommunities = %d %v, want 3 [0 1 0 2 1]", n, community)
+  }
+}
@@ -1,13 +1,12 @@
 package main
 
 import (
+  "flag"
   "log"
   "net/http"
-  "github.com/jmoiron/sqlx"
   "github.com/astaxie/beego/session"
 )
 
-var db *sqlx.DB = ConnectToDatabase()
 var globalSessions *session.Manager
 
 func init() {
@@ -16,11 +15,42 @@ func init() {
 }
 
 func main() {
+  migrate := flag.Bool("migrate", false, "migrate the database schema to the latest version before serving")
+  migrateTo := flag.Int("migrate-to", -1, "migrate the database schema to the given version and exit")
+  backfillRollups := flag.Bool("backfill-rollups", false, "rebuild the term rollups from the stored terms and exit")
+  flag.Parse()
 
-  db.SetMaxOpenConns(20) //tune this
+  store, err := NewStore()
+  if err != nil {
+    log.Fatal(err)
+  }
+  defer store.Close()
 
-  router := NewRouter()
-  defer db.Close()
+  if *migrateTo >= 0 {
+    if err := store.MigrateTo(*migrateTo); err != nil {
+      log.Fatal(err)
+    }
+    return
+  }
+
+  if *migrate {
+    if err := BuildDatabase(store); err != nil {
+      log.Fatal(err)
+    }
+  }
+
+  if err := CheckSchema(store); err != nil {
+    log.Fatal(err)
+  }
+
+  if *backfillRollups {
+    if err := store.BackfillRollups(); err != nil {
+      log.Fatal(err)
+    }
+    return
+  }
+
+  router := NewRouter(NewEngine(store))
 
   log.Fatal(http.ListenAndServe(":8080", router))
 }
\ No newline at end of file
@@ -0,0 +1,921 @@
+package main
+
+import (
+  "bytes"
+  "database/sql"
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+)
+
+// MemoryStore is a Store that keeps everything in process memory. It
+// answers queries the same way PostgresStore does and is intended for tests
+// and local demos; nothing survives a restart.
+type MemoryStore struct {
+  mutex sync.RWMutex
+  miners Miners
+  posts Posts
+  terms Terms
+  stopwords Stopwords
+  aliases Aliases
+  rollups map[Rollup]map[rollupKey]*TermBucket
+  batches map[string]BatchStatus
+  lastMinerId int
+  lastPostId int
+  lastTermId int
+  lastStopwordId int
+  lastAliasId int
+}
+
+// Identifies a row of a rollup
+type rollupKey struct {
+  bucket time.Time
+  term string
+  location string
+  source string
+  lang string
+}
+
+func NewMemoryStore() *MemoryStore {
+  return &MemoryStore{
+    miners: Miners {},
+    posts: Posts {},
+    terms: Terms {},
+    stopwords: Stopwords {},
+    aliases: Aliases {},
+    rollups: newMemoryRollups(),
+    batches: map[string]BatchStatus {},
+  }
+}
+
+func newMemoryRollups() map[Rollup]map[rollupKey]*TermBucket {
+  rollups := map[Rollup]map[rollupKey]*TermBucket {}
+  for _, rollup := range Rollups {
+    rollups[rollup] = map[rollupKey]*TermBucket {}
+  }
+  return rollups
+}
+
+// A MemoryStore has no schema to migrate so always reports the latest
+// version.
+func (s *MemoryStore) SchemaVersion() (int, error) {
+  return LatestSchemaVersion(), nil
+}
+
+func (s *MemoryStore) MigrateTo(version int) error {
+  _, err := MigrationsBetween(LatestSchemaVersion(), version)
+  return err
+}
+
+func (s *MemoryStore) ClearData() error {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  s.posts = Posts {}
+  s.terms = Terms {}
+  s.rollups = newMemoryRollups()
+  return nil
+}
+
+func (s *MemoryStore) ResetMiners() error {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  s.miners = Miners {}
+  s.removeStopwords(StopwordMiner, "")
+  return nil
+}
+
+func (s *MemoryStore) Close() error {
+  return nil
+}
+
+func (s *MemoryStore) Miners() (Miners, error) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  miners := make(Miners, len(s.miners))
+  for i, miner := range s.miners {
+    miners[i] = s.withStopwords(miner)
+  }
+  return miners, nil
+}
+
+func (s *MemoryStore) Miner(uid int) (Miner, error) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  for _, miner := range s.miners {
+    if miner.Uid == uid {
+      return s.withStopwords(miner), nil
+    }
+  }
+  return Miner {}, sql.ErrNoRows
+}
+
+func (s *MemoryStore) InsertMiner(miner Miner) (int, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  s.lastMinerId++
+  miner.Uid = s.lastMinerId
+  s.setMinerStopwords(miner)
+  miner.Stopwords = ""
+  s.miners = append(s.miners, miner)
+  return miner.Uid, nil
+}
+
+func (s *MemoryStore) UpdateMiner(miner Miner) (int64, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  for i := range s.miners {
+    if s.miners[i].Uid == miner.Uid {
+      miner.KeyHash = s.miners[i].KeyHash
+      s.setMinerStopwords(miner)
+      miner.Stopwords = ""
+      s.miners[i] = miner
+      return 1, nil
+    }
+  }
+  return 0, nil
+}
+
+func (s *MemoryStore) InsertBatch(batch BatchStatus) error {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  s.batches[batch.BatchId] = batch
+  return nil
+}
+
+func (s *MemoryStore) UpdateBatch(batch BatchStatus) error {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  if stored, ok := s.batches[batch.BatchId]; ok {
+    batch.MinerId = stored.MinerId
+    batch.Received = stored.Received
+    batch.Posts = stored.Posts
+    batch.Rejected = stored.Rejected
+    s.batches[batch.BatchId] = batch
+  }
+  return nil
+}
+
+func (s *MemoryStore) Batch(id string) (BatchStatus, error) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  batch, ok := s.batches[id]
+  if !ok {
+    return BatchStatus {}, sql.ErrNoRows
+  }
+  return batch, nil
+}
+
+func (s *MemoryStore) DeleteBatches(receivedBefore time.Time) (int64, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  var deleted int64
+  for id, batch := range s.batches {
+    if batch.Received.Before(receivedBefore) {
+      delete(s.batches, id)
+      deleted++
+    }
+  }
+  return deleted, nil
+}
+
+func (s *MemoryStore) SetMinerKeyHash(uid int, keyHash string) (int64, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  for i := range s.miners {
+    if s.miners[i].Uid == uid {
+      s.miners[i].KeyHash = keyHash
+      return 1, nil
+    }
+  }
+  return 0, nil
+}
+
+func (s *MemoryStore) DeleteMiner(uid int) (int64, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  for i := range s.miners {
+    if s.miners[i].Uid == uid {
+      s.miners = append(s.miners[:i], s.miners[i+1:]...)
+      s.removeStopwords(StopwordMiner, strconv.Itoa(uid))
+      return 1, nil
+    }
+  }
+  return 0, nil
+}
+
+func (s *MemoryStore) StopwordsFor(location string, source string) ([]string, error) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  if location == "all" {
+    location = ""
+  }
+  if source == "all" {
+    source = ""
+  }
+
+  miners := map[string]bool {}
+  for _, miner := range s.miners {
+    if (location == "" || miner.Location == location) && (source == "" || miner.Source == source) {
+      miners[strconv.Itoa(miner.Uid)] = true
+    }
+  }
+
+  seen := map[string]bool {}
+  stopwords := []string{}
+  for _, stopword := range s.stopwords {
+    applies := false
+    switch stopword.Scope {
+    case StopwordGlobal:
+      applies = true
+    case StopwordLocation:
+      applies = location == "" || stopword.Target == location
+    case StopwordSource:
+      applies = source == "" || strings.EqualFold(stopword.Target, source)
+    case StopwordMiner:
+      applies = miners[stopword.Target]
+    }
+    if applies && !seen[stopword.Word] {
+      seen[stopword.Word] = true
+      stopwords = append(stopwords, stopword.Word)
+    }
+  }
+  return stopwords, nil
+}
+
+func (s *MemoryStore) Stopwords(scope string, target string) (Stopwords, error) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  stopwords := Stopwords {}
+  for _, stopword := range s.stopwords {
+    if (scope == "" || stopword.Scope == scope) && (target == "" || stopword.Target == target) {
+      stopwords = append(stopwords, stopword)
+    }
+  }
+  sort.SliceStable(stopwords, func(i, j int) bool {
+    a, b := stopwords[i], stopwords[j]
+    if a.Scope != b.Scope {
+      return a.Scope < b.Scope
+    }
+    if a.Target != b.Target {
+      return a.Target < b.Target
+    }
+    return a.Word < b.Word
+  })
+  return stopwords, nil
+}
+
+func (s *MemoryStore) InsertStopwords(stopwords Stopwords) (int, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  return s.insertStopwords(stopwords), nil
+}
+
+func (s *MemoryStore) DeleteStopword(uid int) (int64, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  for i := range s.stopwords {
+    if s.stopwords[i].Uid == uid {
+      s.stopwords = append(s.stopwords[:i], s.stopwords[i+1:]...)
+      return 1, nil
+    }
+  }
+  return 0, nil
+}
+
+func (s *MemoryStore) Aliases() (Aliases, error) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  aliases := append(Aliases {}, s.aliases...)
+  sort.SliceStable(aliases, func(i, j int) bool {
+    if aliases[i].Canonical != aliases[j].Canonical {
+      return aliases[i].Canonical < aliases[j].Canonical
+    }
+    return aliases[i].Term < aliases[j].Term
+  })
+  return aliases, nil
+}
+
+func (s *MemoryStore) InsertAliases(aliases Aliases) (int, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  added := 0
+  for _, alias := range aliases {
+    exists := false
+    for i := range s.aliases {
+      if s.aliases[i].Term == alias.Term {
+        exists = true
+        if s.aliases[i].Canonical != alias.Canonical {
+          s.aliases[i].Canonical = alias.Canonical
+          added++
+        }
+        break
+      }
+    }
+    if exists {
+      continue
+    }
+    s.lastAliasId++
+    alias.Uid = s.lastAliasId
+    s.aliases = append(s.aliases, alias)
+    added++
+  }
+  return added, nil
+}
+
+func (s *MemoryStore) DeleteAlias(uid int) (int64, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  for i := range s.aliases {
+    if s.aliases[i].Uid == uid {
+      s.aliases = append(s.aliases[:i], s.aliases[i+1:]...)
+      return 1, nil
+    }
+  }
+  return 0, nil
+}
+
+func (s *MemoryStore) insertStopwords(stopwords Stopwords) (added int) {
+  for _, stopword := range stopwords {
+    exists := false
+    for _, existing := range s.stopwords {
+      if existing.Word == stopword.Word && existing.Scope == stopword.Scope && existing.Target == stopword.Target {
+        exists = true
+        break
+      }
+    }
+    if exists {
+      continue
+    }
+    s.lastStopwordId++
+    stopword.Uid = s.lastStopwordId
+    s.stopwords = append(s.stopwords, stopword)
+    added++
+  }
+  return
+}
+
+// Removes the stopwords in a scope for target, or for every target when
+// target is "".
+func (s *MemoryStore) removeStopwords(scope string, target string) {
+  kept := Stopwords {}
+  for _, stopword := range s.stopwords {
+    if stopword.Scope != scope || (target != "" && stopword.Target != target) {
+      kept = append(kept, stopword)
+    }
+  }
+  s.stopwords = kept
+}
+
+// Replaces a miner's miner scoped stopwords with those in its Stopwords.
+func (s *MemoryStore) setMinerStopwords(miner Miner) {
+  s.removeStopwords(StopwordMiner, strconv.Itoa(miner.Uid))
+  stopwords, _ := NewStopwords(StopwordMiner, strconv.Itoa(miner.Uid), SplitStopwords(miner.Stopwords))
+  s.insertStopwords(stopwords)
+}
+
+// Returns the miner with Stopwords listing its miner scoped stopwords.
+func (s *MemoryStore) withStopwords(miner Miner) Miner {
+  words := []string {}
+  for _, stopword := range s.stopwords {
+    if stopword.Scope == StopwordMiner && stopword.Target == strconv.Itoa(miner.Uid) {
+      words = append(words, stopword.Word)
+    }
+  }
+  sort.Strings(words)
+  miner.Stopwords = strings.Join(words, ", ")
+  return miner
+}
+
+func (s *MemoryStore) InsertPosts(posts []PostWithTerms) ([]int, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  uids := make([]int, len(posts))
+  for i, p := range posts {
+    if s.hasPost(p.Post) {
+      continue
+    }
+
+    s.lastPostId++
+    post := p.Post
+    post.Uid = s.lastPostId
+    s.posts = append(s.posts, post)
+    uids[i] = post.Uid
+
+    for _, term := range p.Terms {
+      s.lastTermId++
+      term.Uid = s.lastTermId
+      term.PostId = post.Uid
+      term.Term = strings.ToLower(term.Term)
+      term.Posted = post.Posted
+      term.Location = post.Location
+      term.Ngram = NgramOf(term.Term)
+      s.terms = append(s.terms, term)
+    }
+    s.rollupPost(post, p.Terms)
+  }
+  return uids, nil
+}
+
+func (s *MemoryStore) hasPost(post Post) bool {
+  for _, existing := range s.posts {
+    if existing.SourceURI == post.SourceURI && existing.Location == post.Location {
+      return true
+    }
+  }
+  return false
+}
+
+func (s *MemoryStore) post(uid int) (Post, error) {
+  for _, post := range s.posts {
+    if post.Uid == uid {
+      return post, nil
+    }
+  }
+  return Post {}, sql.ErrNoRows
+}
+
+func (s *MemoryStore) PostsCount(location string) (int, error) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  count := 0
+  for _, post := range s.posts {
+    if location == "all" || post.Location == location {
+      count++
+    }
+  }
+  return count, nil
+}
+
+func (s *MemoryStore) LastMined(location string) (time.Time, error) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  var mined time.Time
+  found := false
+  for _, post := range s.posts {
+    if (location == "all" || post.Location == location) && (!found || post.Mined.After(mined)) {
+      mined = post.Mined
+      found = true
+    }
+  }
+  if !found {
+    return mined, sql.ErrNoRows
+  }
+  return mined, nil
+}
+
+// Returns the terms within a window for a location and source whose term
+// matches termPattern, or every term when it is nil.
+func (s *MemoryStore) matchingTerms(source string, location string, termPattern *regexp.Regexp, from time.Time, to time.Time) Terms {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  terms := Terms {}
+  for _, t := range s.terms {
+    if t.Posted.Before(from) || !t.Posted.Before(to) {
+      continue
+    }
+    if termPattern != nil && !termPattern.MatchString(t.Term) {
+      continue
+    }
+    post, err := s.post(t.PostId)
+    if err != nil {
+      continue
+    }
+    if location != "" && post.Location != location {
+      continue
+    }
+    if source != "" && !strings.EqualFold(post.Source, source) {
+      continue
+    }
+    t.Source = post.Source
+    t.Lang = post.Lang
+    terms = append(terms, t)
+  }
+
+  sort.SliceStable(terms, func(i, j int) bool {
+    if !terms[i].Posted.Equal(terms[j].Posted) {
+      return terms[i].Posted.Before(terms[j].Posted)
+    }
+    return terms[i].Term < terms[j].Term
+  })
+  return terms
+}
+
+func (s *MemoryStore) TermBuckets(source string, location string, from time.Time, to time.Time, interval int, ngram NgramRange, lang string) (TermBuckets, error) {
+  terms := s.matchingTerms(source, location, nil, from, to)
+
+  type key struct {
+    term string
+    bucket int
+  }
+  width := to.Sub(from) / time.Duration(interval)
+  index := map[key]int {}
+  buckets := TermBuckets {}
+  for _, t := range terms {
+    if !ngram.Contains(NgramOf(t.Term)) || (lang != "" && t.Lang != lang) {
+      continue
+    }
+    bucket := interval - 1
+    if width > 0 && int(t.Posted.Sub(from) / width) < bucket {
+      bucket = int(t.Posted.Sub(from) / width)
+    }
+    k := key{t.Term, bucket}
+    if _, ok := index[k]; !ok {
+      index[k] = len(buckets)
+      buckets = append(buckets, TermBucket {Term: t.Term, Bucket: bucket})
+    }
+    buckets[index[k]].Occurrences += t.WordCount
+    buckets[index[k]].Mentions++
+  }
+  return buckets, nil
+}
+
+func (s *MemoryStore) TermSourceBuckets(source string, location string, matching []string, from time.Time, to time.Time, interval int) (TermBuckets, error) {
+  terms := s.matchingTerms(source, location, termsPattern(matching), from, to)
+
+  type key struct {
+    term string
+    source string
+    bucket int
+  }
+  width := to.Sub(from) / time.Duration(interval)
+  index := map[key]int {}
+  buckets := TermBuckets {}
+  for _, t := range terms {
+    bucket := interval - 1
+    if width > 0 && int(t.Posted.Sub(from) / width) < bucket {
+      bucket = int(t.Posted.Sub(from) / width)
+    }
+    k := key{t.Term, t.Source, bucket}
+    if _, ok := index[k]; !ok {
+      index[k] = len(buckets)
+      buckets = append(buckets, TermBucket {Term: t.Term, Source: t.Source, Bucket: bucket})
+    }
+    buckets[index[k]].Occurrences += t.WordCount
+    buckets[index[k]].Mentions++
+  }
+  return buckets, nil
+}
+
+func (s *MemoryStore) TermSources(source string, location string, matching []string, from time.Time, to time.Time, limit int) (Posts, error) {
+  terms := s.matchingTerms(source, location, termsPattern(matching), from, to)
+
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  // Each source is listed once, as its first post, with the terms that
+  // post used
+  added := map[string]int {}
+  posts := Posts {}
+  for _, t := range terms {
+    post, err := s.post(t.PostId)
+    if err != nil {
+      continue
+    }
+    if i, ok := added[post.SourceURI]; ok {
+      if posts[i].Uid == post.Uid && !stringInSlice(t.Term, posts[i].Matched) {
+        posts[i].Matched = append(posts[i].Matched, t.Term)
+        sort.Strings(posts[i].Matched)
+      }
+      continue
+    }
+    post.Matched = []string{t.Term}
+    added[post.SourceURI] = len(posts)
+    posts = append(posts, post)
+  }
+
+  sort.SliceStable(posts, func(i, j int) bool {
+    return posts[i].Posted.Before(posts[j].Posted)
+  })
+  if len(posts) > limit {
+    posts = posts[len(posts) - limit:]
+  }
+  return posts, nil
+}
+
+func (s *MemoryStore) RelatedTerms(source string, location string, matching []string, from time.Time, to time.Time, minSupport int, limit int) ([]Related, error) {
+  terms := s.matchingTerms(source, location, termsPattern(matching), from, to)
+  window := s.matchingTerms(source, location, nil, from, to)
+
+  postids := map[int]bool {}
+  for _, t := range terms {
+    postids[t.PostId] = true
+  }
+
+  excluded := map[string]bool {}
+  for _, term := range matching {
+    excluded[strings.ToLower(term)] = true
+  }
+
+  counts := map[string]int {}
+  mentions := map[string]map[int]bool {}
+  background := map[string]map[int]bool {}
+  for _, t := range window {
+    if background[t.Term] == nil {
+      background[t.Term] = map[int]bool {}
+    }
+    background[t.Term][t.PostId] = true
+    if postids[t.PostId] && !excluded[t.Term] {
+      counts[t.Term] += t.WordCount
+      if mentions[t.Term] == nil {
+        mentions[t.Term] = map[int]bool {}
+      }
+      mentions[t.Term][t.PostId] = true
+    }
+  }
+
+  related := []Related {}
+  for _, key := range sortedKeys(counts) {
+    if limit > 0 && len(related) == limit {
+      break
+    }
+    if len(mentions[key]) < minSupport {
+      continue
+    }
+    related = append(related, Related {Term: key, Occurrences: counts[key], Mentions: len(mentions[key]), Background: len(background[key])})
+  }
+  return related, nil
+}
+
+func (s *MemoryStore) CountPosts(source string, location string, matching []string, from time.Time, to time.Time) (int, error) {
+  if len(matching) > 0 {
+    postids := map[int]bool {}
+    for _, t := range s.matchingTerms(source, location, termsPattern(matching), from, to) {
+      postids[t.PostId] = true
+    }
+    return len(postids), nil
+  }
+
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  count := 0
+  for _, post := range s.posts {
+    if post.Posted.Before(from) || !post.Posted.Before(to) {
+      continue
+    }
+    if location != "" && post.Location != location {
+      continue
+    }
+    if source != "" && !strings.EqualFold(post.Source, source) {
+      continue
+    }
+    count++
+  }
+  return count, nil
+}
+
+func (s *MemoryStore) PostBuckets(source string, location string, from time.Time, to time.Time, interval int) ([]int, error) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  width := to.Sub(from) / time.Duration(interval)
+  counts := make([]int, interval)
+  for _, post := range s.posts {
+    if post.Posted.Before(from) || !post.Posted.Before(to) {
+      continue
+    }
+    if location != "" && post.Location != location {
+      continue
+    }
+    if source != "" && !strings.EqualFold(post.Source, source) {
+      continue
+    }
+    bucket := interval - 1
+    if width > 0 && int(post.Posted.Sub(from) / width) < bucket {
+      bucket = int(post.Posted.Sub(from) / width)
+    }
+    counts[bucket]++
+  }
+  return counts, nil
+}
+
+func (s *MemoryStore) CoOccurrences(source string, location string, matching []string, from time.Time, to time.Time) (CoOccurrences, error) {
+  used := map[int][]string {}
+  for _, t := range s.matchingTerms(source, location, termsPattern(matching), from, to) {
+    if !stringInSlice(t.Term, used[t.PostId]) {
+      used[t.PostId] = append(used[t.PostId], t.Term)
+    }
+  }
+
+  type pair struct {
+    term string
+    other string
+  }
+  counts := map[pair]int {}
+  for _, terms := range used {
+    sort.Strings(terms)
+    for i := range terms {
+      for j := i + 1; j < len(terms); j++ {
+        counts[pair{terms[i], terms[j]}]++
+      }
+    }
+  }
+
+  pairs := CoOccurrences {}
+  for p, posts := range counts {
+    pairs = append(pairs, CoOccurrence {Term: p.term, Other: p.other, Posts: posts})
+  }
+  sort.SliceStable(pairs, func(i, j int) bool {
+    if pairs[i].Posts != pairs[j].Posts {
+      return pairs[i].Posts > pairs[j].Posts
+    }
+    if pairs[i].Term != pairs[j].Term {
+      return pairs[i].Term < pairs[j].Term
+    }
+    return pairs[i].Other < pairs[j].Other
+  })
+  return pairs, nil
+}
+
+func (s *MemoryStore) FirstSeen(source string, location string, terms []string) (map[string]time.Time, error) {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  wanted := map[string]bool {}
+  for _, term := range terms {
+    wanted[strings.ToLower(term)] = true
+  }
+
+  firstSeen := map[string]time.Time {}
+  for _, t := range s.terms {
+    if !wanted[t.Term] {
+      continue
+    }
+    post, err := s.post(t.PostId)
+    if err != nil {
+      continue
+    }
+    if location != "" && post.Location != location {
+      continue
+    }
+    if source != "" && !strings.EqualFold(post.Source, source) {
+      continue
+    }
+    if seen, ok := firstSeen[t.Term]; !ok || t.Posted.Before(seen) {
+      firstSeen[t.Term] = t.Posted
+    }
+  }
+  return firstSeen, nil
+}
+
+func (s *MemoryStore) rollupPost(post Post, terms Terms) {
+  for _, rollup := range Rollups {
+    for _, term := range terms {
+      key := rollupKey{rollup.Truncate(post.Posted), strings.ToLower(term.Term), post.Location, post.Source, post.Lang}
+      row, ok := s.rollups[rollup][key]
+      if !ok {
+        row = &TermBucket {Term: key.term, Source: key.source}
+        s.rollups[rollup][key] = row
+      }
+      row.Occurrences += term.WordCount
+      row.Mentions++
+    }
+  }
+}
+
+func (s *MemoryStore) BackfillRollups() error {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+
+  s.rollups = newMemoryRollups()
+  for _, post := range s.posts {
+    terms := Terms {}
+    for _, t := range s.terms {
+      if t.PostId == post.Uid {
+        terms = append(terms, t)
+      }
+    }
+    s.rollupPost(post, terms)
+  }
+  return nil
+}
+
+// Buckets the rows of a rollup matching the filters, keyed by the row's
+// term or, when bySource is set, its source.
+func (s *MemoryStore) rollupBuckets(rollup Rollup, source string, location string, termPattern *regexp.Regexp, from time.Time, to time.Time, interval int, ngram NgramRange, lang string, bySource bool) TermBuckets {
+  s.mutex.RLock()
+  defer s.mutex.RUnlock()
+
+  type key struct {
+    term string
+    source string
+    bucket int
+  }
+  width := to.Sub(from) / time.Duration(interval)
+  index := map[key]int {}
+  buckets := TermBuckets {}
+  for k, row := range s.rollups[rollup] {
+    if k.bucket.Before(from) || !k.bucket.Before(to) {
+      continue
+    }
+    if location != "" && k.location != location {
+      continue
+    }
+    if source != "" && !strings.EqualFold(k.source, source) {
+      continue
+    }
+    if termPattern != nil && !termPattern.MatchString(k.term) {
+      continue
+    }
+    if !ngram.Contains(NgramOf(k.term)) || (lang != "" && k.lang != lang) {
+      continue
+    }
+    bucket := interval - 1
+    if int(k.bucket.Sub(from) / width) < bucket {
+      bucket = int(k.bucket.Sub(from) / width)
+    }
+    bk := key{k.term, "", bucket}
+    if bySource {
+      bk.source = k.source
+    }
+    if _, ok := index[bk]; !ok {
+      index[bk] = len(buckets)
+      buckets = append(buckets, TermBucket {Term: bk.term, Source: bk.source, Bucket: bucket})
+    }
+    buckets[index[bk]].Occurrences += row.Occurrences
+    buckets[index[bk]].Mentions += row.Mentions
+  }
+  return buckets
+}
+
+func (s *MemoryStore) RollupTermBuckets(rollup Rollup, source string, location string, from time.Time, to time.Time, interval int, ngram NgramRange, lang string) (TermBuckets, error) {
+  return s.rollupBuckets(rollup, source, location, nil, from, to, interval, ngram, lang, false), nil
+}
+
+func (s *MemoryStore) RollupTermSourceBuckets(rollup Rollup, source string, location string, terms []string, from time.Time, to time.Time, interval int) (TermBuckets, error) {
+  return s.rollupBuckets(rollup, source, location, termsPattern(terms), from, to, interval, AnyNgram, "", true), nil
+}
+
+func (s *MemoryStore) TermSliceBuckets(source string, location string, matching []string, from time.Time, width time.Duration, slices int, length time.Duration, ngram NgramRange, lang string) (TermBuckets, error) {
+  var termPattern *regexp.Regexp
+  if len(matching) > 0 {
+    termPattern = termsPattern(matching)
+  }
+  to := from.Add(width * time.Duration(slices - 1) + length)
+  terms := s.matchingTerms(source, location, termPattern, from, to)
+
+  type key struct {
+    term string
+    source string
+    bucket int
+  }
+  index := map[key]int {}
+  buckets := TermBuckets {}
+  for _, t := range terms {
+    if !ngram.Contains(NgramOf(t.Term)) || (lang != "" && t.Lang != lang) {
+      continue
+    }
+    slice := int(t.Posted.Sub(from) / width)
+    if slice >= slices || t.Posted.Sub(from) - width * time.Duration(slice) >= length {
+      continue
+    }
+    k := key{t.Term, t.Source, slice}
+    if _, ok := index[k]; !ok {
+      index[k] = len(buckets)
+      buckets = append(buckets, TermBucket {Term: t.Term, Source: t.Source, Bucket: slice})
+    }
+    buckets[index[k]].Occurrences += t.WordCount
+    buckets[index[k]].Mentions++
+  }
+  return buckets, nil
+}
+
+// Compiles a list of terms into a case insensitive regular expression
+// matching exactly any of them, as matchingTermsCondition does.
+func termsPattern(terms []string) *regexp.Regexp {
+  var pattern bytes.Buffer
+  pattern.WriteString("(?i)^(?:")
+  for i, term := range terms {
+    if i > 0 {
+      pattern.WriteString("|")
+    }
+    pattern.WriteString(regexp.QuoteMeta(term))
+  }
+  pattern.WriteString(")$")
+  return regexp.MustCompile(pattern.String())
+}
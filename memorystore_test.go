@@ -0,0 +1,75 @@
+package main
+
+import (
+  "testing"
+  "time"
+)
+
+// A fixed hour the tests' posts are dated around, so windows don't depend
+// on the clock
+var testNow = time.Date(2026, 3, 10, 14, 25, 0, 0, time.UTC)
+
+// Builds a post with its terms as a miner would send them.
+func testPost(uri string, location string, source string, posted time.Time, terms map[string]int) PostWithTerms {
+  post := PostWithTerms {
+    Post: Post {
+      SourceURI: uri,
+      Location: location,
+      Source: source,
+      Posted: posted,
+      Mined: posted,
+      Lang: "en",
+    },
+    Terms: Terms {},
+  }
+  for term, count := range terms {
+    post.Terms = append(post.Terms, Term {Term: term, WordCount: count, Source: source, Lang: "en"})
+  }
+  return post
+}
+
+// Returns a MemoryStore holding posts.
+func newTestStore(t *testing.T, posts ...PostWithTerms) *MemoryStore {
+  store := NewMemoryStore()
+  if _, err := store.InsertPosts(posts); err != nil {
+    t.Fatalf("InsertPosts: %v", err)
+  }
+  return store
+}
+
+// Formats a time as the from and to query parameters are.
+func testParam(t time.Time) string {
+  return t.Format("200601021504")
+}
+
+func TestMemoryStoreInsertPostsSkipsDuplicates(t *testing.T) {
+  store := NewMemoryStore()
+  first := testPost("http://t/1", "nairobi", "twitter", testNow, map[string]int {"jobs": 1})
+  uids, err := store.InsertPosts([]PostWithTerms {first})
+  if err != nil {
+    t.Fatalf("InsertPosts: %v", err)
+  }
+  if uids[0] == 0 {
+    t.Fatalf("first post got uid 0")
+  }
+
+  // The same URI is a duplicate in the same location but not in another
+  again := testPost("http://t/1", "nairobi", "twitter", testNow, map[string]int {"jobs": 1})
+  elsewhere := testPost("http://t/1", "lagos", "twitter", testNow, map[string]int {"jobs": 1})
+  uids, err = store.InsertPosts([]PostWithTerms {again, elsewhere})
+  if err != nil {
+    t.Fatalf("InsertPosts: %v", err)
+  }
+  if uids[0] != 0 || uids[1] == 0 {
+    t.Errorf("uids = %v, want the duplicate skipped with uid 0", uids)
+  }
+
+  count, _ := store.PostsCount("nairobi")
+  if count != 1 {
+    t.Errorf("PostsCount(nairobi) = %d, want 1", count)
+  }
+  buckets, _ := store.TermBuckets("", "nairobi", testNow.Add(-time.Hour), testNow.Add(time.Hour), 1, AnyNgram, "")
+  if len(buckets) != 1 || buckets[0].Mentions != 1 {
+    t.Errorf("nairobi buckets = %v, want jobs in one post", buckets)
+  }
+}
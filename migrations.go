@@ -0,0 +1,307 @@
+package main
+
+import (
+  "fmt"
+)
+
+// A Migration moves the database schema from Version - 1 to Version (Up)
+// and back again (Down). Each direction is applied in a single transaction.
+type Migration struct {
+  Version int
+  Name string
+  Up []string
+  Down []string
+}
+
+// The schema history, oldest first. Never edit a migration once it has been
+// released; add a new one instead. Statements are written so that databases
+// created before schema_version existed can be adopted by migrating them.
+var migrations = []Migration{
+  Migration{
+    Version: 1,
+    Name: "create posts, terms and miners",
+    Up: []string{
+      "CREATE TABLE IF NOT EXISTS posts(uid serial NOT NULL, mined timestamp without time zone, posted timestamp without time zone, sourceURI text, location text, source text, locationhash bigint)",
+      "CREATE TABLE IF NOT EXISTS terms(uid serial NOT NULL, postid integer, term text,  wordcount integer, posted timestamp without time zone, location text, locationhash bigint)",
+      "CREATE TABLE IF NOT EXISTS miners(uid serial NOT NULL, name text, source text, location text, url text, geocoord point, locationhash bigint)",
+    },
+    Down: []string{
+      "DROP TABLE IF EXISTS posts",
+      "DROP TABLE IF EXISTS terms",
+      "DROP TABLE IF EXISTS miners",
+    },
+  },
+  Migration{
+    Version: 2,
+    Name: "index uid and locationhash",
+    Up: []string{
+      "CREATE INDEX IF NOT EXISTS posts_uid_idx ON posts (uid)",
+      "CREATE INDEX IF NOT EXISTS terms_uid_idx ON terms (uid)",
+      "CREATE INDEX IF NOT EXISTS miners_uid_idx ON miners (uid)",
+      "CREATE INDEX IF NOT EXISTS posts_locationhash_idx ON posts (locationhash)",
+      "CREATE INDEX IF NOT EXISTS terms_locationhash_idx ON terms (locationhash)",
+      "CREATE INDEX IF NOT EXISTS miners_locationhash_idx ON miners (locationhash)",
+    },
+    Down: []string{
+      "DROP INDEX IF EXISTS posts_uid_idx",
+      "DROP INDEX IF EXISTS terms_uid_idx",
+      "DROP INDEX IF EXISTS miners_uid_idx",
+      "DROP INDEX IF EXISTS posts_locationhash_idx",
+      "DROP INDEX IF EXISTS terms_locationhash_idx",
+      "DROP INDEX IF EXISTS miners_locationhash_idx",
+    },
+  },
+  Migration{
+    Version: 3,
+    Name: "add miner stopwords",
+    Up: []string{
+      "ALTER TABLE miners ADD COLUMN IF NOT EXISTS stopwords varchar(255) DEFAULT ''",
+    },
+    Down: []string{
+      "ALTER TABLE miners DROP COLUMN IF EXISTS stopwords",
+    },
+  },
+  Migration{
+    Version: 4,
+    Name: "index terms by location and posted",
+    Up: []string{
+      "CREATE INDEX IF NOT EXISTS terms_locationhash_posted_idx ON terms (locationhash, posted)",
+      "CREATE INDEX IF NOT EXISTS terms_posted_idx ON terms (posted)",
+    },
+    Down: []string{
+      "DROP INDEX IF EXISTS terms_locationhash_posted_idx",
+      "DROP INDEX IF EXISTS terms_posted_idx",
+    },
+  },
+  Migration{
+    Version: 5,
+    Name: "index terms by postid and term",
+    Up: []string{
+      "CREATE INDEX IF NOT EXISTS terms_postid_idx ON terms (postid)",
+      "CREATE INDEX IF NOT EXISTS terms_term_posted_idx ON terms (term text_pattern_ops, posted)",
+    },
+    Down: []string{
+      "DROP INDEX IF EXISTS terms_postid_idx",
+      "DROP INDEX IF EXISTS terms_term_posted_idx",
+    },
+  },
+  Migration{
+    Version: 6,
+    Name: "create hourly and daily term rollups",
+    Up: []string{
+      "CREATE TABLE IF NOT EXISTS term_rollups_hourly(bucket timestamp without time zone NOT NULL, term text NOT NULL, locationhash bigint NOT NULL, location text, source text NOT NULL, occurrences bigint NOT NULL DEFAULT 0, mentions bigint NOT NULL DEFAULT 0, PRIMARY KEY (bucket, term, locationhash, source))",
+      "CREATE INDEX IF NOT EXISTS term_rollups_hourly_locationhash_bucket_idx ON term_rollups_hourly (locationhash, bucket)",
+      "CREATE INDEX IF NOT EXISTS term_rollups_hourly_term_bucket_idx ON term_rollups_hourly (term text_pattern_ops, bucket)",
+      "CREATE TABLE IF NOT EXISTS term_rollups_daily(bucket timestamp without time zone NOT NULL, term text NOT NULL, locationhash bigint NOT NULL, location text, source text NOT NULL, occurrences bigint NOT NULL DEFAULT 0, mentions bigint NOT NULL DEFAULT 0, PRIMARY KEY (bucket, term, locationhash, source))",
+      "CREATE INDEX IF NOT EXISTS term_rollups_daily_locationhash_bucket_idx ON term_rollups_daily (locationhash, bucket)",
+      "CREATE INDEX IF NOT EXISTS term_rollups_daily_term_bucket_idx ON term_rollups_daily (term text_pattern_ops, bucket)",
+    },
+    Down: []string{
+      "DROP TABLE IF EXISTS term_rollups_hourly",
+      "DROP TABLE IF EXISTS term_rollups_daily",
+    },
+  },
+  Migration{
+    Version: 7,
+    Name: "make posts unique by sourceURI and location",
+    Up: []string{
+      // Remove any repeats stored before the constraint existed, keeping the first
+      "DELETE FROM terms WHERE postid IN (SELECT later.uid FROM posts later JOIN posts earlier ON later.sourceURI = earlier.sourceURI AND later.locationhash = earlier.locationhash AND later.uid > earlier.uid)",
+      "DELETE FROM posts later USING posts earlier WHERE later.sourceURI = earlier.sourceURI AND later.locationhash = earlier.locationhash AND later.uid > earlier.uid",
+      "CREATE UNIQUE INDEX IF NOT EXISTS posts_sourceuri_locationhash_key ON posts (sourceURI, locationhash)",
+    },
+    Down: []string{
+      "DROP INDEX IF EXISTS posts_sourceuri_locationhash_key",
+    },
+  },
+  Migration{
+    Version: 8,
+    Name: "add miner secrets",
+    Up: []string{
+      "ALTER TABLE miners ADD COLUMN IF NOT EXISTS secret text NOT NULL DEFAULT ''",
+    },
+    Down: []string{
+      "ALTER TABLE miners DROP COLUMN IF EXISTS secret",
+    },
+  },
+  Migration{
+    Version: 9,
+    Name: "store posted and mined with time zone",
+    Up: []string{
+      // Existing values were written as UTC
+      "ALTER TABLE posts ALTER COLUMN posted TYPE timestamp with time zone USING posted AT TIME ZONE 'UTC', ALTER COLUMN mined TYPE timestamp with time zone USING mined AT TIME ZONE 'UTC'",
+      "ALTER TABLE terms ALTER COLUMN posted TYPE timestamp with time zone USING posted AT TIME ZONE 'UTC'",
+    },
+    Down: []string{
+      "ALTER TABLE posts ALTER COLUMN posted TYPE timestamp without time zone USING posted AT TIME ZONE 'UTC', ALTER COLUMN mined TYPE timestamp without time zone USING mined AT TIME ZONE 'UTC'",
+      "ALTER TABLE terms ALTER COLUMN posted TYPE timestamp without time zone USING posted AT TIME ZONE 'UTC'",
+    },
+  },
+  Migration{
+    Version: 10,
+    Name: "add term ngram length",
+    Up: []string{
+      "ALTER TABLE terms ADD COLUMN IF NOT EXISTS ngram smallint NOT NULL DEFAULT 1",
+      "UPDATE terms SET ngram = array_length(regexp_split_to_array(btrim(term), '[[:space:]]+'), 1) WHERE btrim(term) LIKE '% %'",
+      "ALTER TABLE term_rollups_hourly ADD COLUMN IF NOT EXISTS ngram smallint NOT NULL DEFAULT 1",
+      "UPDATE term_rollups_hourly SET ngram = array_length(regexp_split_to_array(btrim(term), '[[:space:]]+'), 1) WHERE btrim(term) LIKE '% %'",
+      "ALTER TABLE term_rollups_daily ADD COLUMN IF NOT EXISTS ngram smallint NOT NULL DEFAULT 1",
+      "UPDATE term_rollups_daily SET ngram = array_length(regexp_split_to_array(btrim(term), '[[:space:]]+'), 1) WHERE btrim(term) LIKE '% %'",
+    },
+    Down: []string{
+      "ALTER TABLE terms DROP COLUMN IF EXISTS ngram",
+      "ALTER TABLE term_rollups_hourly DROP COLUMN IF EXISTS ngram",
+      "ALTER TABLE term_rollups_daily DROP COLUMN IF EXISTS ngram",
+    },
+  },
+  Migration{
+    Version: 11,
+    Name: "add post language",
+    Up: []string{
+      "ALTER TABLE posts ADD COLUMN IF NOT EXISTS lang text NOT NULL DEFAULT ''",
+      "ALTER TABLE term_rollups_hourly ADD COLUMN IF NOT EXISTS lang text NOT NULL DEFAULT ''",
+      "ALTER TABLE term_rollups_hourly DROP CONSTRAINT IF EXISTS term_rollups_hourly_pkey, ADD PRIMARY KEY (bucket, term, locationhash, source, lang)",
+      "ALTER TABLE term_rollups_daily ADD COLUMN IF NOT EXISTS lang text NOT NULL DEFAULT ''",
+      "ALTER TABLE term_rollups_daily DROP CONSTRAINT IF EXISTS term_rollups_daily_pkey, ADD PRIMARY KEY (bucket, term, locationhash, source, lang)",
+    },
+    Down: []string{
+      // Rows for the same term in different languages can't be kept apart
+      // without the column; run -backfill-rollups after migrating down
+      "TRUNCATE term_rollups_hourly, term_rollups_daily",
+      "ALTER TABLE term_rollups_hourly DROP CONSTRAINT IF EXISTS term_rollups_hourly_pkey, DROP COLUMN IF EXISTS lang, ADD PRIMARY KEY (bucket, term, locationhash, source)",
+      "ALTER TABLE term_rollups_daily DROP CONSTRAINT IF EXISTS term_rollups_daily_pkey, DROP COLUMN IF EXISTS lang, ADD PRIMARY KEY (bucket, term, locationhash, source)",
+      "ALTER TABLE posts DROP COLUMN IF EXISTS lang",
+    },
+  },
+  Migration{
+    Version: 12,
+    Name: "move stopwords to their own table",
+    Up: []string{
+      "CREATE TABLE IF NOT EXISTS stopwords(uid serial PRIMARY KEY, word text NOT NULL, scope text NOT NULL, target text NOT NULL DEFAULT '', created timestamp with time zone NOT NULL DEFAULT now())",
+      "CREATE UNIQUE INDEX IF NOT EXISTS stopwords_word_scope_target_key ON stopwords (word, scope, target)",
+      "CREATE INDEX IF NOT EXISTS stopwords_scope_target_idx ON stopwords (scope, target)",
+      "INSERT INTO stopwords (word, scope, target) SELECT DISTINCT lower(btrim(word)), 'miner', miners.uid::text FROM miners, unnest(string_to_array(miners.stopwords, ',')) AS word WHERE btrim(word) <> '' ON CONFLICT DO NOTHING",
+      "ALTER TABLE miners DROP COLUMN IF EXISTS stopwords",
+    },
+    Down: []string{
+      "ALTER TABLE miners ADD COLUMN IF NOT EXISTS stopwords varchar(255) DEFAULT ''",
+      "UPDATE miners SET stopwords = left(words.list, 255) FROM (SELECT target, string_agg(word, ',' ORDER BY word) AS list FROM stopwords WHERE scope = 'miner' GROUP BY target) words WHERE words.target = miners.uid::text",
+      "DROP TABLE IF EXISTS stopwords",
+    },
+  },
+  Migration{
+    Version: 13,
+    Name: "create aliases",
+    Up: []string{
+      "CREATE TABLE IF NOT EXISTS aliases(uid serial PRIMARY KEY, term text NOT NULL UNIQUE, canonical text NOT NULL, created timestamp with time zone NOT NULL DEFAULT now())",
+      "CREATE INDEX IF NOT EXISTS aliases_canonical_idx ON aliases (canonical)",
+    },
+    Down: []string{
+      "DROP TABLE IF EXISTS aliases",
+    },
+  },
+  Migration{
+    Version: 14,
+    Name: "store miner signing keys instead of secrets",
+    Up: []string{
+      "UPDATE miners SET secret = encode(sha256(convert_to(secret, 'UTF8')), 'hex') WHERE secret <> ''",
+      "ALTER TABLE miners RENAME COLUMN secret TO signing_key",
+    },
+    Down: []string{
+      // Secrets can't be read back from their signing keys, so every miner
+      // needs a new API key after migrating down
+      "ALTER TABLE miners RENAME COLUMN signing_key TO secret",
+      "UPDATE miners SET secret = ''",
+    },
+  },
+  Migration{
+    Version: 15,
+    Name: "create batches",
+    Up: []string{
+      "CREATE TABLE IF NOT EXISTS batches(id text PRIMARY KEY, minerid integer NOT NULL, status text NOT NULL, received timestamp with time zone NOT NULL, completed timestamp with time zone, posts integer NOT NULL DEFAULT 0, accepted integer NOT NULL DEFAULT 0, duplicate integer NOT NULL DEFAULT 0, failed integer NOT NULL DEFAULT 0, rejected integer NOT NULL DEFAULT 0, error text NOT NULL DEFAULT '')",
+      "CREATE INDEX IF NOT EXISTS batches_received_idx ON batches (received)",
+    },
+    Down: []string{
+      "DROP TABLE IF EXISTS batches",
+    },
+  },
+  Migration{
+    Version: 16,
+    Name: "name miner key hashes",
+    Up: []string{
+      // The keys were already stored hashed, only request bodies are no
+      // longer signed with them
+      "ALTER TABLE miners RENAME COLUMN signing_key TO key_hash",
+    },
+    Down: []string{
+      "ALTER TABLE miners RENAME COLUMN key_hash TO signing_key",
+    },
+  },
+  Migration{
+    Version: 17,
+    Name: "store rollup buckets with time zone",
+    Up: []string{
+      // Existing buckets were written as UTC
+      "ALTER TABLE term_rollups_hourly ALTER COLUMN bucket TYPE timestamp with time zone USING bucket AT TIME ZONE 'UTC'",
+      "ALTER TABLE term_rollups_daily ALTER COLUMN bucket TYPE timestamp with time zone USING bucket AT TIME ZONE 'UTC'",
+    },
+    Down: []string{
+      "ALTER TABLE term_rollups_hourly ALTER COLUMN bucket TYPE timestamp without time zone USING bucket AT TIME ZONE 'UTC'",
+      "ALTER TABLE term_rollups_daily ALTER COLUMN bucket TYPE timestamp without time zone USING bucket AT TIME ZONE 'UTC'",
+    },
+  },
+}
+
+// Returns the schema version this build of the engine expects.
+func LatestSchemaVersion() int {
+  return migrations[len(migrations) - 1].Version
+}
+
+// Returns the migrations needed to take the schema from version current to
+// version target, in the order they should be applied. When migrating down
+// the returned migrations should be applied using their Down statements.
+func MigrationsBetween(current int, target int) (steps []Migration, err error) {
+  if target < 0 || target > LatestSchemaVersion() {
+    return nil, fmt.Errorf("Unknown schema version %d, latest is %d", target, LatestSchemaVersion())
+  }
+  if current > LatestSchemaVersion() {
+    return nil, fmt.Errorf("Database schema version %d is newer than this engine (%d)", current, LatestSchemaVersion())
+  }
+
+  if target >= current {
+    for _, migration := range migrations {
+      if migration.Version > current && migration.Version <= target {
+        steps = append(steps, migration)
+      }
+    }
+  } else {
+    for i := len(migrations) - 1; i >= 0; i-- {
+      if migrations[i].Version <= current && migrations[i].Version > target {
+        steps = append(steps, migrations[i])
+      }
+    }
+  }
+  return
+}
+
+// Brings the schema up to the latest version. Some migrations change or
+// remove data on the way up and can't be undone by migrating down: 7
+// deletes repeated posts and their terms, 12 drops miners.stopwords once
+// its words are copied to the stopwords table, and 14 replaces miner
+// secrets with their hashes. Back up the database before upgrading past
+// them; see the README's upgrade notes.
+func BuildDatabase(store Store) error {
+  return store.MigrateTo(LatestSchemaVersion())
+}
+
+// Returns an error unless the store's schema is at the latest version.
+func CheckSchema(store Store) error {
+  version, err := store.SchemaVersion()
+  if err != nil {
+    return err
+  }
+  if version != LatestSchemaVersion() {
+    return fmt.Errorf("Database schema is at version %d but this engine requires version %d; run with -migrate to upgrade", version, LatestSchemaVersion())
+  }
+  return nil
+}
@@ -8,6 +8,9 @@ type Miner struct {
   Source string `json:"source"`
   Url string `json:"url"`
   Stopwords string `json:"stopwords"`
+  // The hash of the miner's API key by MinerKeyHash, never rendered. The
+  // API key itself isn't stored.
+  KeyHash string `json:"-"`
 }
 
 type Miners []Miner
\ No newline at end of file
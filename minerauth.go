@@ -0,0 +1,47 @@
+package main
+
+import (
+  "crypto/rand"
+  "crypto/sha256"
+  "crypto/subtle"
+  "encoding/hex"
+  "fmt"
+  "net/http"
+  "strings"
+)
+
+// Returns a new random miner secret.
+func NewMinerSecret() (string, error) {
+  b := make([]byte, 32)
+  if _, err := rand.Read(b); err != nil {
+    return "", err
+  }
+  return hex.EncodeToString(b), nil
+}
+
+// Returns the hash stored for a miner in place of its secret, the hex
+// encoded SHA-256 of the secret. Bearer tokens are checked by hashing them,
+// so the secret can't be read back from the store and the hash can't be
+// used in its place.
+func MinerKeyHash(secret string) string {
+  sum := sha256.Sum256([]byte(secret))
+  return hex.EncodeToString(sum[:])
+}
+
+// Checks that a request was sent by miner, with its secret as a bearer
+// token in the Authorization header.
+func AuthenticateMiner(miner Miner, r *http.Request) error {
+  if miner.KeyHash == "" {
+    return fmt.Errorf("Miner %d has no API key, generate one in the miners admin", miner.Uid)
+  }
+
+  authorization := r.Header.Get("Authorization")
+  if !strings.HasPrefix(authorization, "Bearer ") {
+    return fmt.Errorf("Missing API key, send it as a bearer token in the Authorization header")
+  }
+  token := strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+  if subtle.ConstantTimeCompare([]byte(MinerKeyHash(token)), []byte(miner.KeyHash)) != 1 {
+    return fmt.Errorf("API key does not match miner %d", miner.Uid)
+  }
+  return nil
+}
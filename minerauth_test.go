@@ -0,0 +1,40 @@
+package main
+
+import (
+  "net/http/httptest"
+  "testing"
+)
+
+func TestAuthenticateMiner(t *testing.T) {
+  secret, err := NewMinerSecret()
+  if err != nil {
+    t.Fatalf("NewMinerSecret: %v", err)
+  }
+  miner := Miner {Uid: 1, KeyHash: MinerKeyHash(secret)}
+
+  tests := []struct {
+    name string
+    authorization string
+    ok bool
+  }{
+    {"api key", "Bearer " + secret, true},
+    {"wrong key", "Bearer nope", false},
+    {"stored hash", "Bearer " + miner.KeyHash, false},
+    {"no key", "", false},
+  }
+  for _, test := range tests {
+    r := httptest.NewRequest("POST", "/v1/minerpost", nil)
+    if test.authorization != "" {
+      r.Header.Set("Authorization", test.authorization)
+    }
+    if err := AuthenticateMiner(miner, r); (err == nil) != test.ok {
+      t.Errorf("%s: AuthenticateMiner = %v, want ok %v", test.name, err, test.ok)
+    }
+  }
+
+  r := httptest.NewRequest("POST", "/v1/minerpost", nil)
+  r.Header.Set("Authorization", "Bearer " + secret)
+  if err := AuthenticateMiner(Miner {Uid: 2}, r); err == nil {
+    t.Errorf("a miner without an API key was authenticated")
+  }
+}
@@ -1,31 +1,236 @@
 package main
 
 import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strconv"
     "strings"
     "time"
 )
 
+// How far ahead of the engine's clock a miner's timestamps may be before
+// the post is rejected as being in the future.
+const MinerClockSkew = 5 * time.Minute
+
+// Posts are kept raw so that each can be decoded and validated on its own
+// and one bad post does not reject the whole batch.
 type MinerPostsJSON struct {
-  Posts []MinerPostJSON `json:"posts"`
+  Posts []json.RawMessage `json:"posts"`
   MinerId string `json:"miner_id"`
 }
 
+// A post sent by a miner, with either its terms already counted or its raw
+// text for the engine to tokenize.
 type MinerPostJSON struct {
   Terms map[string]int `json:"terms"`
+  Text string `json:"text,omitempty"`
+  // ISO 639-1 code, detected from the post when the miner leaves it out
+  Lang string `json:"lang,omitempty"`
   Url string `json:"url"`
   Datetime myTime `json:"datetime"`
   MinedAt myTime `json:"mined_at"`
 }
 
+// The outcome of validating one post of a batch, in the order posted.
+type MinerPostResult struct {
+  Index int `json:"index"`
+  Url string `json:"url,omitempty"`
+  Lang string `json:"lang,omitempty"`
+  Valid bool `json:"valid"`
+  Errors []string `json:"errors,omitempty"`
+}
+
+// The reply to a minerpost: the queued batch, if any post was valid, and
+// the result for every post.
+type MinerPostResponse struct {
+  *BatchStatus
+  Error string `json:"error,omitempty"`
+  Results []MinerPostResult `json:"results"`
+}
+
+// The legacy minute precision timestamp format, read as UTC
+const LegacyTimeFormat = "200601021504"
+
+// Epoch values at or above this are taken to be milliseconds
+const epochMillisThreshold = 100000000000
+
+// A miner timestamp. Accepts an RFC3339 string, keeping its zone, Unix
+// epoch seconds or milliseconds, or the legacy 200601021504 format, each
+// either as a JSON number or a string.
 type myTime struct {
   time.Time
 }
 
 func (t *myTime) UnmarshalJSON(buf []byte) error {
-  tt, err := time.Parse("200601021504", strings.Trim(string(buf), `"`))
+  tt, err := ParseMinerTime(strings.Trim(string(buf), `"`))
   if err != nil {
     return err
   }
   t.Time = tt
   return nil
+}
+
+// Parses a timestamp in any of the formats miners may send.
+func ParseMinerTime(value string) (time.Time, error) {
+  value = strings.TrimSpace(value)
+  if value == "" {
+    return time.Time{}, fmt.Errorf("empty timestamp")
+  }
+
+  if isDigits(value) {
+    if len(value) == len(LegacyTimeFormat) {
+      return time.Parse(LegacyTimeFormat, value)
+    }
+    epoch, err := strconv.ParseInt(value, 10, 64)
+    if err != nil {
+      return time.Time{}, err
+    }
+    if epoch >= epochMillisThreshold {
+      return time.Unix(0, epoch * int64(time.Millisecond)).UTC(), nil
+    }
+    return time.Unix(epoch, 0).UTC(), nil
+  }
+
+  tt, err := time.Parse(time.RFC3339Nano, value)
+  if err != nil {
+    return time.Time{}, fmt.Errorf("expected RFC3339, epoch seconds or milliseconds, or %s, got %q", LegacyTimeFormat, value)
+  }
+  return tt, nil
+}
+
+func isDigits(value string) bool {
+  for _, c := range value {
+    if c < '0' || c > '9' {
+      return false
+    }
+  }
+  return true
+}
+
+// Decodes the post at index of a batch and checks it can be stored: the
+// url is present, both timestamps parse and are not in the future, and
+// there is at least one term, each with a positive count. The language is
+// detected unless given, and text is counted into terms by tokenizer,
+// removing the language's stopwords, which are also removed from terms
+// sent as they are. Every problem found is reported, not just the first.
+func ValidateMinerPost(index int, raw json.RawMessage, now time.Time, tokenizer Tokenizer) (post MinerPostJSON, result MinerPostResult) {
+  result.Index = index
+
+  var fields map[string]json.RawMessage
+  if err := json.Unmarshal(raw, &fields); err != nil {
+    result.Errors = append(result.Errors, fmt.Sprintf("post is not a JSON object: %v", err))
+    return
+  }
+
+  if value, ok := fields["url"]; ok {
+    if err := json.Unmarshal(value, &post.Url); err != nil {
+      result.Errors = append(result.Errors, fmt.Sprintf("url must be a string: %v", err))
+    }
+  }
+  post.Url = strings.TrimSpace(post.Url)
+  result.Url = post.Url
+  if post.Url == "" {
+    result.Errors = append(result.Errors, "url is required")
+  }
+
+  for _, field := range []struct {
+    name string
+    time *myTime
+  }{
+    {"datetime", &post.Datetime},
+    {"mined_at", &post.MinedAt},
+  } {
+    value, ok := fields[field.name]
+    if !ok || string(value) == "null" {
+      result.Errors = append(result.Errors, field.name + " is required")
+      continue
+    }
+    if err := json.Unmarshal(value, field.time); err != nil {
+      result.Errors = append(result.Errors, fmt.Sprintf("%s is not a valid time: %v", field.name, err))
+      continue
+    }
+    if field.time.After(now.Add(MinerClockSkew)) {
+      result.Errors = append(result.Errors, fmt.Sprintf("%s %s is in the future", field.name, field.time.Format(time.RFC3339)))
+    }
+  }
+
+  if value, ok := fields["terms"]; ok {
+    if err := json.Unmarshal(value, &post.Terms); err != nil {
+      result.Errors = append(result.Errors, fmt.Sprintf("terms must map each term to a whole number count: %v", err))
+    }
+  }
+  if value, ok := fields["text"]; ok {
+    if err := json.Unmarshal(value, &post.Text); err != nil {
+      result.Errors = append(result.Errors, fmt.Sprintf("text must be a string: %v", err))
+    }
+  }
+  if value, ok := fields["lang"]; ok {
+    if err := json.Unmarshal(value, &post.Lang); err != nil {
+      result.Errors = append(result.Errors, fmt.Sprintf("lang must be a string: %v", err))
+    }
+  }
+  post.Lang = strings.ToLower(strings.TrimSpace(post.Lang))
+  if post.Lang != "" && !isLanguageCode(post.Lang) {
+    result.Errors = append(result.Errors, fmt.Sprintf("lang %q is not an ISO 639 language code such as en", post.Lang))
+  }
+  if post.Lang == "" {
+    if post.Text != "" {
+      post.Lang = DetectLanguage(LanguageWords(post.Text))
+    } else {
+      words := []string {}
+      for term := range post.Terms {
+        words = append(words, LanguageWords(term)...)
+      }
+      post.Lang = DetectLanguage(words)
+    }
+  }
+  result.Lang = post.Lang
+
+  if post.Text != "" {
+    if len(post.Terms) > 0 {
+      result.Errors = append(result.Errors, "send either terms or text, not both")
+    } else {
+      post.Terms = tokenizer.ForLanguage(post.Lang).Count(post.Text)
+      if len(post.Terms) == 0 {
+        result.Errors = append(result.Errors, "text has no terms once tokenized")
+      }
+    }
+  } else if len(post.Terms) == 0 {
+    result.Errors = append(result.Errors, "terms must not be empty")
+  } else {
+    post.Terms = tokenizer.ForLanguage(post.Lang).FilterTerms(post.Terms)
+    if len(post.Terms) == 0 {
+      result.Errors = append(result.Errors, "terms has no terms once stopwords are removed")
+    }
+  }
+  terms := []string {}
+  for term := range post.Terms {
+    terms = append(terms, term)
+  }
+  sort.Strings(terms)
+  for _, term := range terms {
+    count := post.Terms[term]
+    if strings.TrimSpace(term) == "" {
+      result.Errors = append(result.Errors, "terms must not contain a blank term")
+    }
+    if count < 1 {
+      result.Errors = append(result.Errors, fmt.Sprintf("count for term %q must be positive, got %d", term, count))
+    }
+  }
+
+  result.Valid = len(result.Errors) == 0
+  return
+}
+
+func isLanguageCode(code string) bool {
+  if len(code) < 2 || len(code) > 3 {
+    return false
+  }
+  for _, c := range code {
+    if c < 'a' || c > 'z' {
+      return false
+    }
+  }
+  return true
 }
\ No newline at end of file
@@ -0,0 +1,46 @@
+package main
+
+import (
+  "encoding/json"
+  "reflect"
+  "testing"
+)
+
+func TestValidateMinerPostRemovesStopwordsFromTerms(t *testing.T) {
+  tokenizer := NewTokenizer(DefaultTokenizerConfig().WithStopwords("rt"))
+  tests := []struct {
+    name string
+    post string
+    lang string
+    terms map[string]int
+  }{
+    {"declared", `{"lang": "en", "terms": {"the": 3, "Robots": 2, "the robots": 1, "3d printing": 1, "rt": 1}}`, "en", map[string]int {"Robots": 2, "3d printing": 1}},
+    {"detected", `{"terms": {"de": 4, "und": 2, "la": 2, "el": 1, "los": 1, "empleo": 3}}`, "es", map[string]int {"und": 2, "empleo": 3}},
+    {"unknown language", `{"terms": {"und": 2, "roboter": 1}}`, "", map[string]int {"und": 2, "roboter": 1}},
+  }
+  for _, test := range tests {
+    raw := map[string]json.RawMessage {}
+    json.Unmarshal([]byte(test.post), &raw)
+    raw["url"] = json.RawMessage(`"http://t/1"`)
+    raw["datetime"] = json.RawMessage(`201603101400`)
+    raw["mined_at"] = json.RawMessage(`201603101410`)
+    body, _ := json.Marshal(raw)
+
+    post, result := ValidateMinerPost(0, body, testNow, tokenizer)
+    if !result.Valid {
+      t.Errorf("%s: post is invalid: %v", test.name, result.Errors)
+      continue
+    }
+    if post.Lang != test.lang || !reflect.DeepEqual(post.Terms, test.terms) {
+      t.Errorf("%s: post = %s %v, want %s %v", test.name, post.Lang, post.Terms, test.lang, test.terms)
+    }
+  }
+}
+
+func TestValidateMinerPostRejectsOnlyStopwords(t *testing.T) {
+  body := `{"lang": "en", "terms": {"the": 3, "and": 1}, "url": "http://t/1", "datetime": 201603101400, "mined_at": 201603101410}`
+  _, result := ValidateMinerPost(0, json.RawMessage(body), testNow, NewTokenizer(DefaultTokenizerConfig()))
+  if result.Valid {
+    t.Errorf("a post of only stopwords is valid")
+  }
+}
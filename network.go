@@ -0,0 +1,211 @@
+package main
+
+import (
+  "encoding/xml"
+  "fmt"
+  "io"
+  "time"
+)
+
+// A term in a co-occurrence network, identified by its place in the
+// network's Nodes
+type NetworkNode struct {
+  Id int `json:"id"`
+  Term string `json:"term"`
+  Occurrences int `json:"occurrences"`
+  Velocity float64 `json:"velocity"`
+  Score float64 `json:"score"`
+}
+
+// Two terms used together, weighted by the number of posts using both
+type NetworkEdge struct {
+  Source int `json:"source"`
+  Target int `json:"target"`
+  Weight int `json:"weight"`
+}
+
+// The terms trending in a location over a window and how often each pair
+// is used together. Edges are undirected, the heaviest first.
+type Network struct {
+  Location string `json:"location"`
+  From time.Time `json:"from"`
+  To time.Time `json:"to"`
+  Nodes []NetworkNode `json:"nodes"`
+  Edges []NetworkEdge `json:"edges"`
+}
+
+// Formats a network can be exported in
+const (
+  NetworkJSON = "json"
+  NetworkGEXF = "gexf"
+  NetworkGraphML = "graphml"
+)
+
+type gexfAttribute struct {
+  Id int `xml:"id,attr"`
+  Title string `xml:"title,attr"`
+  Type string `xml:"type,attr"`
+}
+
+type gexfAttValue struct {
+  For int `xml:"for,attr"`
+  Value string `xml:"value,attr"`
+}
+
+type gexfNode struct {
+  Id int `xml:"id,attr"`
+  Label string `xml:"label,attr"`
+  AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+  Id int `xml:"id,attr"`
+  Source int `xml:"source,attr"`
+  Target int `xml:"target,attr"`
+  Weight int `xml:"weight,attr"`
+}
+
+type gexfAttributes struct {
+  Class string `xml:"class,attr"`
+  Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfGraph struct {
+  Mode string `xml:"mode,attr"`
+  DefaultEdgeType string `xml:"defaultedgetype,attr"`
+  Attributes gexfAttributes `xml:"attributes"`
+  Nodes []gexfNode `xml:"nodes>node"`
+  Edges []gexfEdge `xml:"edges>edge"`
+}
+
+type gexfDocument struct {
+  XMLName xml.Name `xml:"http://www.gexf.net/1.2draft gexf"`
+  Version string `xml:"version,attr"`
+  Creator string `xml:"meta>creator"`
+  Description string `xml:"meta>description"`
+  Graph gexfGraph `xml:"graph"`
+}
+
+// Writes the network as GEXF 1.2, for Gephi
+func (n Network) WriteGEXF(w io.Writer) error {
+  doc := gexfDocument {
+    Version: "1.2",
+    Creator: "Udadisi Engine",
+    Description: n.description(),
+    Graph: gexfGraph {
+      Mode: "static",
+      DefaultEdgeType: "undirected",
+      Attributes: gexfAttributes {
+        Class: "node",
+        Attributes: []gexfAttribute {
+          {Id: 0, Title: "occurrences", Type: "integer"},
+          {Id: 1, Title: "velocity", Type: "double"},
+          {Id: 2, Title: "score", Type: "double"},
+        },
+      },
+    },
+  }
+  for _, node := range n.Nodes {
+    doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode {
+      Id: node.Id,
+      Label: node.Term,
+      AttValues: []gexfAttValue {
+        {For: 0, Value: fmt.Sprint(node.Occurrences)},
+        {For: 1, Value: fmt.Sprint(node.Velocity)},
+        {For: 2, Value: fmt.Sprint(node.Score)},
+      },
+    })
+  }
+  for i, edge := range n.Edges {
+    doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge {Id: i, Source: edge.Source, Target: edge.Target, Weight: edge.Weight})
+  }
+  return writeXML(w, doc)
+}
+
+type graphMLKey struct {
+  Id string `xml:"id,attr"`
+  For string `xml:"for,attr"`
+  Name string `xml:"attr.name,attr"`
+  Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+  Key string `xml:"key,attr"`
+  Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+  Id string `xml:"id,attr"`
+  Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+  Id string `xml:"id,attr"`
+  Source string `xml:"source,attr"`
+  Target string `xml:"target,attr"`
+  Data []graphMLData `xml:"data"`
+}
+
+type graphMLGraph struct {
+  Id string `xml:"id,attr"`
+  EdgeDefault string `xml:"edgedefault,attr"`
+  Nodes []graphMLNode `xml:"node"`
+  Edges []graphMLEdge `xml:"edge"`
+}
+
+type graphMLDocument struct {
+  XMLName xml.Name `xml:"http://graphml.graphdrawing.org/xmlns graphml"`
+  Keys []graphMLKey `xml:"key"`
+  Graph graphMLGraph `xml:"graph"`
+}
+
+// Writes the network as GraphML
+func (n Network) WriteGraphML(w io.Writer) error {
+  doc := graphMLDocument {
+    Keys: []graphMLKey {
+      {Id: "label", For: "node", Name: "label", Type: "string"},
+      {Id: "occurrences", For: "node", Name: "occurrences", Type: "int"},
+      {Id: "velocity", For: "node", Name: "velocity", Type: "double"},
+      {Id: "score", For: "node", Name: "score", Type: "double"},
+      {Id: "weight", For: "edge", Name: "weight", Type: "int"},
+    },
+    Graph: graphMLGraph {
+      Id: "G",
+      EdgeDefault: "undirected",
+    },
+  }
+  for _, node := range n.Nodes {
+    doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode {
+      Id: fmt.Sprintf("n%d", node.Id),
+      Data: []graphMLData {
+        {Key: "label", Value: node.Term},
+        {Key: "occurrences", Value: fmt.Sprint(node.Occurrences)},
+        {Key: "velocity", Value: fmt.Sprint(node.Velocity)},
+        {Key: "score", Value: fmt.Sprint(node.Score)},
+      },
+    })
+  }
+  for i, edge := range n.Edges {
+    doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge {
+      Id: fmt.Sprintf("e%d", i),
+      Source: fmt.Sprintf("n%d", edge.Source),
+      Target: fmt.Sprintf("n%d", edge.Target),
+      Data: []graphMLData {{Key: "weight", Value: fmt.Sprint(edge.Weight)}},
+    })
+  }
+  return writeXML(w, doc)
+}
+
+// Describes the network's location and window
+func (n Network) description() string {
+  return fmt.Sprintf("%s %s - %s", n.Location, n.From.Format("200601021504"), n.To.Format("200601021504"))
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+  if _, err := io.WriteString(w, xml.Header); err != nil {
+    return err
+  }
+  encoder := xml.NewEncoder(w)
+  encoder.Indent("", "  ")
+  return encoder.Encode(doc)
+}
\ No newline at end of file
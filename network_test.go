@@ -0,0 +1,114 @@
+package main
+
+import (
+  "bytes"
+  "encoding/xml"
+  "reflect"
+  "sort"
+  "strconv"
+  "strings"
+  "testing"
+  "time"
+)
+
+func testNetwork() Network {
+  return Network {
+    Location: "nairobi",
+    From: testNow.Add(-24 * time.Hour),
+    To: testNow,
+    Nodes: []NetworkNode {
+      {Id: 0, Term: "jobs", Occurrences: 12, Velocity: 1.5, Score: 2.25},
+      {Id: 1, Term: "r&d", Occurrences: 4, Velocity: 0, Score: 0.5},
+    },
+    Edges: []NetworkEdge {{Source: 0, Target: 1, Weight: 3}},
+  }
+}
+
+func TestNetworkWriteGEXF(t *testing.T) {
+  var out bytes.Buffer
+  if err := testNetwork().WriteGEXF(&out); err != nil {
+    t.Fatalf("WriteGEXF: %v", err)
+  }
+  if !strings.HasPrefix(out.String(), xml.Header) {
+    t.Errorf("GEXF doesn't start with an XML header")
+  }
+
+  var doc gexfDocument
+  if err := xml.Unmarshal(out.Bytes(), &doc); err != nil {
+    t.Fatalf("GEXF doesn't parse: %v\n%s", err, out.String())
+  }
+  if doc.XMLName.Space != "http://www.gexf.net/1.2draft" || doc.Version != "1.2" {
+    t.Errorf("GEXF root = %v version %s", doc.XMLName, doc.Version)
+  }
+  if doc.Graph.DefaultEdgeType != "undirected" || len(doc.Graph.Attributes.Attributes) != 3 {
+    t.Errorf("GEXF graph = %+v", doc.Graph)
+  }
+  if len(doc.Graph.Nodes) != 2 || doc.Graph.Nodes[1].Label != "r&d" || doc.Graph.Nodes[0].AttValues[0].Value != "12" || doc.Graph.Nodes[0].AttValues[2].Value != "2.25" {
+    t.Errorf("GEXF nodes = %+v", doc.Graph.Nodes)
+  }
+  if len(doc.Graph.Edges) != 1 || doc.Graph.Edges[0] != (gexfEdge {Id: 0, Source: 0, Target: 1, Weight: 3}) {
+    t.Errorf("GEXF edges = %+v", doc.Graph.Edges)
+  }
+}
+
+func TestNetworkWriteGraphML(t *testing.T) {
+  var out bytes.Buffer
+  if err := testNetwork().WriteGraphML(&out); err != nil {
+    t.Fatalf("WriteGraphML: %v", err)
+  }
+
+  var doc graphMLDocument
+  if err := xml.Unmarshal(out.Bytes(), &doc); err != nil {
+    t.Fatalf("GraphML doesn't parse: %v\n%s", err, out.String())
+  }
+  if doc.XMLName.Space != "http://graphml.graphdrawing.org/xmlns" || doc.Graph.EdgeDefault != "undirected" {
+    t.Errorf("GraphML root = %v, graph %+v", doc.XMLName, doc.Graph)
+  }
+  keys := map[string]bool {}
+  for _, key := range doc.Keys {
+    keys[key.Id] = true
+  }
+  if len(doc.Graph.Nodes) != 2 || doc.Graph.Nodes[1].Id != "n1" || doc.Graph.Nodes[1].Data[0].Value != "r&d" {
+    t.Errorf("GraphML nodes = %+v", doc.Graph.Nodes)
+  }
+  for _, node := range doc.Graph.Nodes {
+    for _, data := range node.Data {
+      if !keys[data.Key] {
+        t.Errorf("GraphML node data uses undeclared key %q", data.Key)
+      }
+    }
+  }
+  if len(doc.Graph.Edges) != 1 || doc.Graph.Edges[0].Source != "n0" || doc.Graph.Edges[0].Target != "n1" || doc.Graph.Edges[0].Data[0].Value != "3" {
+    t.Errorf("GraphML edges = %+v", doc.Graph.Edges)
+  }
+}
+
+func TestNetworkCollectionFiltersBeforeLimiting(t *testing.T) {
+  posts := []PostWithTerms {
+    // Bursting terms ranked first but used too little
+    testPost("http://t/a", "nairobi", "twitter", testNow.Add(-30 * time.Minute), map[string]int {"a": 2}),
+    testPost("http://t/b1", "nairobi", "twitter", testNow.Add(-30 * time.Minute), map[string]int {"b": 1}),
+    testPost("http://t/b2", "nairobi", "twitter", testNow.Add(-20 * time.Minute), map[string]int {"b": 1}),
+  }
+  for i := 0; i < 6; i++ {
+    posted := testNow.Add(-time.Duration(30 + 60 * (i % 2)) * time.Minute)
+    posts = append(posts, testPost("http://t/" + strconv.Itoa(i), "nairobi", "twitter", posted, map[string]int {"steady": 2, "calm": 1}))
+  }
+  store := newTestStore(t, posts...)
+
+  network, err := NetworkCollection(store, "nairobi", "", testParam(testNow.Add(-2 * time.Hour)), testParam(testNow), 2, 2, 5, 1, true, UnigramsOnly, "", "")
+  if err != nil {
+    t.Fatalf("NetworkCollection: %v", err)
+  }
+  terms := []string {}
+  for _, node := range network.Nodes {
+    terms = append(terms, node.Term)
+  }
+  sort.Strings(terms)
+  if !reflect.DeepEqual(terms, []string {"calm", "steady"}) {
+    t.Errorf("network nodes = %v, want the two terms used at least 5 times", terms)
+  }
+  if len(network.Edges) != 1 || network.Edges[0].Weight != 6 {
+    t.Errorf("network edges = %+v, want calm and steady linked by 6 posts", network.Edges)
+  }
+}
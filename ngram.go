@@ -0,0 +1,79 @@
+package main
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+)
+
+// The longest phrase extracted from post text
+const MaxNgram = 3
+
+// A range of phrase lengths, in words, to include in a query. The zero
+// value includes every length.
+type NgramRange struct {
+  Min int
+  Max int
+}
+
+var (
+  AnyNgram = NgramRange{}
+  UnigramsOnly = NgramRange{1, 1}
+)
+
+// Parses the ngram query parameter: a phrase length from 1 to MaxNgram,
+// "phrases" for every multi-word term or "all" for words and phrases
+// together. Defaults to single words.
+func ParseNgramRange(param string) (NgramRange, error) {
+  switch strings.ToLower(param) {
+  case "":
+    return UnigramsOnly, nil
+  case "phrases":
+    return NgramRange{2, MaxNgram}, nil
+  case "all":
+    return NgramRange{1, MaxNgram}, nil
+  }
+  n, err := strconv.Atoi(param)
+  if err != nil || n < 1 || n > MaxNgram {
+    return UnigramsOnly, fmt.Errorf("Invalid ngram %q, expected 1 to %d, phrases or all", param, MaxNgram)
+  }
+  return NgramRange{n, n}, nil
+}
+
+// Returns whether a phrase of n words is in the range. The top of the
+// range also takes in longer phrases sent by miners.
+func (r NgramRange) Contains(n int) bool {
+  if r.Max == 0 {
+    return true
+  }
+  return n >= r.Min && (n <= r.Max || r.Max == MaxNgram)
+}
+
+// Returns the number of words in a term; more than one makes it a phrase.
+func NgramOf(term string) int {
+  if n := len(strings.Fields(term)); n > 1 {
+    return n
+  }
+  return 1
+}
+
+// Returns the phrases of n consecutive tokens. An empty token breaks a
+// phrase, so words either side of a removed stopword or a sentence end
+// are never joined.
+func Ngrams(tokens []string, n int) (ngrams []string) {
+  ngrams = []string {}
+  for start := 0; start + n <= len(tokens); start++ {
+    window := tokens[start:start + n]
+    complete := true
+    for _, token := range window {
+      if token == "" {
+        complete = false
+        break
+      }
+    }
+    if complete {
+      ngrams = append(ngrams, strings.Join(window, " "))
+    }
+  }
+  return
+}
\ No newline at end of file
@@ -0,0 +1,202 @@
+package main
+
+import (
+  "strings"
+)
+
+// The Porter (1980) stemming algorithm for English.
+type PorterStemmer struct{}
+
+type porterRule struct {
+  suffix string
+  replacement string
+}
+
+var porterStep2 = []porterRule{
+  {"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+  {"izer", "ize"}, {"abli", "able"}, {"alli", "al"}, {"entli", "ent"},
+  {"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+  {"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+  {"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+}
+
+var porterStep3 = []porterRule{
+  {"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+  {"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+var porterStep4 = []string{
+  "al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+  "ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+// Returns the stem of word. Words of two letters or fewer, and words that
+// are not plain lowercase letters, are returned unchanged.
+func (PorterStemmer) Stem(word string) string {
+  if len(word) <= 2 {
+    return word
+  }
+  for _, c := range word {
+    if c < 'a' || c > 'z' {
+      return word
+    }
+  }
+
+  w := word
+  w = porterStep1a(w)
+  w = porterStep1b(w)
+  w = porterStep1c(w)
+  w = porterReplace(w, porterStep2)
+  w = porterReplace(w, porterStep3)
+  w = porterStep4Remove(w)
+  w = porterStep5(w)
+  return w
+}
+
+func porterStep1a(w string) string {
+  switch {
+  case strings.HasSuffix(w, "sses"):
+    return w[:len(w) - 2]
+  case strings.HasSuffix(w, "ies"):
+    return w[:len(w) - 2]
+  case strings.HasSuffix(w, "ss"):
+    return w
+  case strings.HasSuffix(w, "s"):
+    return w[:len(w) - 1]
+  }
+  return w
+}
+
+func porterStep1b(w string) string {
+  if strings.HasSuffix(w, "eed") {
+    if porterMeasure(w[:len(w) - 3]) > 0 {
+      return w[:len(w) - 1]
+    }
+    return w
+  }
+
+  var stem string
+  switch {
+  case strings.HasSuffix(w, "ed") && porterHasVowel(w[:len(w) - 2]):
+    stem = w[:len(w) - 2]
+  case strings.HasSuffix(w, "ing") && porterHasVowel(w[:len(w) - 3]):
+    stem = w[:len(w) - 3]
+  default:
+    return w
+  }
+
+  switch {
+  case strings.HasSuffix(stem, "at") || strings.HasSuffix(stem, "bl") || strings.HasSuffix(stem, "iz"):
+    return stem + "e"
+  case porterEndsDouble(stem) && !strings.ContainsAny(stem[len(stem) - 1:], "lsz"):
+    return stem[:len(stem) - 1]
+  case porterMeasure(stem) == 1 && porterEndsCVC(stem):
+    return stem + "e"
+  }
+  return stem
+}
+
+func porterStep1c(w string) string {
+  if strings.HasSuffix(w, "y") && porterHasVowel(w[:len(w) - 1]) {
+    return w[:len(w) - 1] + "i"
+  }
+  return w
+}
+
+// Applies the first rule whose suffix w ends with, provided the stem left
+// has a measure greater than 0.
+func porterReplace(w string, rules []porterRule) string {
+  for _, rule := range rules {
+    if strings.HasSuffix(w, rule.suffix) {
+      stem := w[:len(w) - len(rule.suffix)]
+      if porterMeasure(stem) > 0 {
+        return stem + rule.replacement
+      }
+      return w
+    }
+  }
+  return w
+}
+
+func porterStep4Remove(w string) string {
+  for _, suffix := range porterStep4 {
+    if !strings.HasSuffix(w, suffix) {
+      continue
+    }
+    stem := w[:len(w) - len(suffix)]
+    if suffix == "ion" && !(strings.HasSuffix(stem, "s") || strings.HasSuffix(stem, "t")) {
+      continue
+    }
+    if porterMeasure(stem) > 1 {
+      return stem
+    }
+    return w
+  }
+  return w
+}
+
+func porterStep5(w string) string {
+  if strings.HasSuffix(w, "e") {
+    stem := w[:len(w) - 1]
+    m := porterMeasure(stem)
+    if m > 1 || (m == 1 && !porterEndsCVC(stem)) {
+      w = stem
+    }
+  }
+  if porterMeasure(w) > 1 && porterEndsDouble(w) && strings.HasSuffix(w, "l") {
+    w = w[:len(w) - 1]
+  }
+  return w
+}
+
+// Returns whether the letter at i is a consonant. Y is a consonant at the
+// start of a word or after a vowel.
+func porterIsConsonant(w string, i int) bool {
+  switch w[i] {
+  case 'a', 'e', 'i', 'o', 'u':
+    return false
+  case 'y':
+    return i == 0 || !porterIsConsonant(w, i - 1)
+  }
+  return true
+}
+
+// Returns m, the number of vowel-consonant sequences in [C](VC){m}[V].
+func porterMeasure(w string) (m int) {
+  vowel := false
+  for i := range w {
+    if porterIsConsonant(w, i) {
+      if vowel {
+        m++
+      }
+      vowel = false
+    } else {
+      vowel = true
+    }
+  }
+  return
+}
+
+func porterHasVowel(w string) bool {
+  for i := range w {
+    if !porterIsConsonant(w, i) {
+      return true
+    }
+  }
+  return false
+}
+
+func porterEndsDouble(w string) bool {
+  n := len(w)
+  return n >= 2 && w[n - 1] == w[n - 2] && porterIsConsonant(w, n - 1)
+}
+
+// Returns whether w ends consonant-vowel-consonant, where the last
+// consonant is not w, x or y, as in "hop" but not "snow".
+func porterEndsCVC(w string) bool {
+  n := len(w)
+  if n < 3 || !porterIsConsonant(w, n - 3) || porterIsConsonant(w, n - 2) || !porterIsConsonant(w, n - 1) {
+    return false
+  }
+  return !strings.ContainsAny(w[n - 1:], "wxy")
+}
\ No newline at end of file
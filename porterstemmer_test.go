@@ -0,0 +1,88 @@
+package main
+
+import (
+  "testing"
+  "time"
+)
+
+// Words and their stems from Porter's paper, covering each step
+var porterVocabulary = map[string]string {
+  // Step 1a
+  "caresses": "caress", "ponies": "poni", "ties": "ti", "caress": "caress", "cats": "cat",
+  // Step 1b
+  "feed": "feed", "agreed": "agre", "plastered": "plaster", "bled": "bled",
+  "motoring": "motor", "sing": "sing", "conflated": "conflat", "troubled": "troubl",
+  "sized": "size", "hopping": "hop", "tanned": "tan", "falling": "fall",
+  "hissing": "hiss", "fizzed": "fizz", "failing": "fail", "filing": "file",
+  // Step 1c
+  "happy": "happi", "sky": "sky",
+  // Step 2
+  "relational": "relat", "conditional": "condit", "rational": "ration",
+  "valenci": "valenc", "hesitanci": "hesit", "digitizer": "digit",
+  "conformabli": "conform", "radicalli": "radic", "differentli": "differ",
+  "vileli": "vile", "analogousli": "analog", "vietnamization": "vietnam",
+  "predication": "predic", "operator": "oper", "feudalism": "feudal",
+  "decisiveness": "decis", "hopefulness": "hope", "callousness": "callous",
+  "formaliti": "formal", "sensitiviti": "sensit", "sensibiliti": "sensibl",
+  // Step 3
+  "triplicate": "triplic", "formative": "form", "formalize": "formal",
+  "electriciti": "electr", "electrical": "electr", "hopeful": "hope", "goodness": "good",
+  // Step 4
+  "revival": "reviv", "allowance": "allow", "inference": "infer", "airliner": "airlin",
+  "gyroscopic": "gyroscop", "adjustable": "adjust", "defensible": "defens",
+  "irritant": "irrit", "replacement": "replac", "adjustment": "adjust",
+  "dependent": "depend", "adoption": "adopt", "homologou": "homolog",
+  "communism": "commun", "activate": "activ", "angulariti": "angular",
+  "homologous": "homolog", "effective": "effect", "bowdlerize": "bowdler",
+  // Step 5
+  "probate": "probat", "rate": "rate", "cease": "ceas", "controll": "control", "roll": "roll",
+  // Left alone
+  "ai": "ai", "#ai": "#ai", "covid19": "covid19", "café": "café",
+}
+
+func TestPorterStemmer(t *testing.T) {
+  stemmer := PorterStemmer{}
+  for word, stem := range porterVocabulary {
+    if got := stemmer.Stem(word); got != stem {
+      t.Errorf("Stem(%q) = %q, want %q", word, got, stem)
+    }
+  }
+}
+
+func TestStemTerm(t *testing.T) {
+  if got := StemTerm(PorterStemmer{}, "running robots"); got != "run robot" {
+    t.Errorf("StemTerm(running robots) = %q, want \"run robot\"", got)
+  }
+  if got := StemTerm(nil, "running robots"); got != "running robots" {
+    t.Errorf("StemTerm without a stemmer = %q, want it unchanged", got)
+  }
+  if _, err := StemmerFor("xx"); err == nil {
+    t.Errorf("StemmerFor accepted an unknown language")
+  }
+}
+
+func TestStemmedTrendsNameTheirMostUsedForm(t *testing.T) {
+  store := newTestStore(t,
+    testPost("http://t/1", "nairobi", "twitter", testNow.Add(-90 * time.Minute), map[string]int {"happy": 3}),
+    testPost("http://t/2", "nairobi", "twitter", testNow.Add(-30 * time.Minute), map[string]int {"happiness": 1, "happy": 1}),
+  )
+
+  counts, err := WordCountRootCollection(store, "nairobi", "", testParam(testNow.Add(-2 * time.Hour)), testParam(testNow), 2, 10, UnigramsOnly, PorterStemmer{}, "", "")
+  if err != nil {
+    t.Fatalf("WordCountRootCollection: %v", err)
+  }
+  if len(counts) != 1 || counts[0].Term != "happy" || counts[0].Stem != "happi" || counts[0].Occurrences != 5 {
+    t.Fatalf("stemmed trends = %+v, want happy under the stem happi with 5 occurrences", counts)
+  }
+  if len(counts[0].Forms) != 2 {
+    t.Errorf("forms = %v, want happy and happiness", counts[0].Forms)
+  }
+
+  // Without stemming Stem is left empty
+  counts, _ = WordCountRootCollection(store, "nairobi", "", testParam(testNow.Add(-2 * time.Hour)), testParam(testNow), 2, 10, UnigramsOnly, nil, "", "")
+  for _, count := range counts {
+    if count.Stem != "" {
+      t.Errorf("unstemmed trend %s has stem %q", count.Term, count.Stem)
+    }
+  }
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+    "time"
+)
+
+type Post struct {
+  Uid int `json:"id"`
+  Mined time.Time `json:"mined"`
+  Posted time.Time `json:"posted"`
+  SourceURI string `json:"source_uri"`
+  Location string `json:"location"`
+  Source string `json:"source"`
+  Lang string `json:"lang"`
+  // The terms a post used out of those it was found by, see TermSources
+  Matched []string `json:"matched,omitempty"`
+}
+
+type Posts []Post
+
+// A post and the terms counted in it, as received from a miner.
+type PostWithTerms struct {
+  Post Post
+  Terms Terms
+}
\ No newline at end of file
@@ -1,6 +1,106 @@
 package main
 
+import (
+  "fmt"
+  "math"
+  "sort"
+)
+
 type Related struct {
   Term string `json:"term"`
   Occurrences  int `json:"occurrences"`
+  // Posts using both the term and the related term, and posts in the window
+  // using the related term at all
+  Mentions int `json:"mentions"`
+  Background int `json:"background"`
+  // How strongly the terms are associated, see ScoreRelated
+  Score float64 `json:"score"`
+}
+
+// Ways of scoring related terms. Count ranks by raw co-occurrences, which
+// favours terms that are common everywhere; pmi and llr weigh the
+// co-occurrences against how often the related term is used in the window
+// at all.
+const (
+  RelatedByCount = "count"
+  RelatedByPMI = "pmi"
+  RelatedByLLR = "llr"
+  DefaultRelatedScoring = RelatedByCount
+)
+
+// Default minimum number of posts a related term must share with the term.
+// Association scores are noisy for terms seen together only once or twice.
+const (
+  DefaultRelatedMinSupport = 1
+  DefaultAssociationMinSupport = 3
+)
+
+// Returns the scoring named, DefaultRelatedScoring for "", or an error if
+// there is no such scoring.
+func RelatedScoringFor(name string) (string, error) {
+  switch name {
+  case "":
+    return DefaultRelatedScoring, nil
+  case RelatedByCount, RelatedByPMI, RelatedByLLR:
+    return name, nil
+  }
+  return "", fmt.Errorf("Unknown related scoring %s, use one of %s, %s or %s", name, RelatedByCount, RelatedByPMI, RelatedByLLR)
+}
+
+// Returns the minimum support to use for a scoring when none was requested.
+func defaultMinSupport(scoring string) int {
+  if scoring == RelatedByCount {
+    return DefaultRelatedMinSupport
+  }
+  return DefaultAssociationMinSupport
 }
+
+// Scores related terms and sorts them highest first. termPosts is the
+// number of posts in the window using the term and totalPosts the number of
+// posts in the window.
+//
+// pmi is the pointwise mutual information log2(P(term, related) /
+// (P(term) P(related))), positive when the terms are used together more
+// than chance would explain. llr is Dunning's log-likelihood ratio of the
+// related term's rate in posts using the term against its rate in the
+// other posts, kept only when the rate is higher with the term and zero
+// otherwise.
+func ScoreRelated(related []Related, scoring string, termPosts int, totalPosts int) {
+  for i := range related {
+    r := &related[i]
+    switch scoring {
+    case RelatedByPMI:
+      r.Score = pointwiseMutualInformation(r.Mentions, termPosts, r.Background, totalPosts)
+    case RelatedByLLR:
+      r.Score = associationLogLikelihood(r.Mentions, termPosts, r.Background, totalPosts)
+    default:
+      r.Score = float64(r.Occurrences)
+    }
+  }
+
+  sort.SliceStable(related, func(i, j int) bool {
+    if related[i].Score != related[j].Score {
+      return related[i].Score > related[j].Score
+    }
+    return related[i].Term < related[j].Term
+  })
+}
+
+func pointwiseMutualInformation(together int, termPosts int, relatedPosts int, totalPosts int) float64 {
+  if together == 0 || termPosts == 0 || relatedPosts == 0 {
+    return 0
+  }
+  return math.Log2(float64(together) * float64(totalPosts) / (float64(termPosts) * float64(relatedPosts)))
+}
+
+func associationLogLikelihood(together int, termPosts int, relatedPosts int, totalPosts int) float64 {
+  otherPosts := totalPosts - termPosts
+  without := relatedPosts - together
+  if termPosts == 0 || otherPosts <= 0 {
+    return 0
+  }
+  if float64(together) / float64(termPosts) <= float64(without) / float64(otherPosts) {
+    return 0
+  }
+  return logLikelihood(float64(together), float64(without), float64(termPosts), float64(otherPosts))
+}
\ No newline at end of file
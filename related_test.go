@@ -0,0 +1,26 @@
+package main
+
+import (
+  "testing"
+  "time"
+)
+
+func TestRelatedScoringFor(t *testing.T) {
+  if scoring, err := RelatedScoringFor(""); err != nil || scoring != DefaultRelatedScoring {
+    t.Errorf("RelatedScoringFor(\"\") = %q, %v, want the default scoring", scoring, err)
+  }
+  if scoring, err := RelatedScoringFor(RelatedByLLR); err != nil || scoring != RelatedByLLR {
+    t.Errorf("RelatedScoringFor(llr) = %q, %v", scoring, err)
+  }
+  if _, err := RelatedScoringFor("jaccard"); err == nil {
+    t.Errorf("RelatedScoringFor accepted an unknown scoring")
+  }
+}
+
+func TestTrendsCollectionRejectsUnknownRelatedScoring(t *testing.T) {
+  store := newTestStore(t, testPost("http://t/1", "nairobi", "twitter", testNow.Add(-30 * time.Minute), map[string]int {"jobs": 1}))
+  _, err := TrendsCollection(store, "", "nairobi", "jobs", testParam(testNow.Add(-time.Hour)), testParam(testNow), 2, 1.0, 0.0, 0, 0, "jaccard", 0)
+  if _, ok := err.(ValidationError); !ok {
+    t.Errorf("TrendsCollection with an unknown related scoring = %v, want a ValidationError", err)
+  }
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+  "time"
+)
+
+// A Rollup names one of the pre-aggregated term count tables. Each holds
+// the occurrences and mentions of a term per location and source for a
+// fixed width of time, and is kept up to date as posts are ingested.
+type Rollup string
+
+const (
+  NoRollup Rollup = ""
+  HourlyRollup Rollup = "hourly"
+  DailyRollup Rollup = "daily"
+)
+
+var Rollups = []Rollup{HourlyRollup, DailyRollup}
+
+// Returns the width of time each row of the rollup covers.
+func (r Rollup) Width() time.Duration {
+  switch r {
+  case HourlyRollup:
+    return time.Hour
+  case DailyRollup:
+    return 24 * time.Hour
+  }
+  return 0
+}
+
+// Returns the date_trunc field that truncates a timestamp to the rollup.
+func (r Rollup) Precision() string {
+  switch r {
+  case HourlyRollup:
+    return "hour"
+  case DailyRollup:
+    return "day"
+  }
+  return ""
+}
+
+// Returns the name of the table holding the rollup.
+func (r Rollup) Table() string {
+  return "term_rollups_" + string(r)
+}
+
+// Returns the start of the rollup row t falls in.
+func (r Rollup) Truncate(t time.Time) time.Time {
+  return t.UTC().Truncate(r.Width())
+}
+
+// Picks the rollup that can answer a window split into interval buckets.
+// When each bucket is a whole number of hours the rollup is returned along
+// with the window rounded down to end on the hour, so that every rollup row
+// falls inside one bucket and every bucket is complete. Otherwise NoRollup
+// and the window unchanged are returned and the raw terms should be used.
+// See RollupWindowBuckets for reading the window itself.
+func RollupWindow(from time.Time, to time.Time, interval int) (rollup Rollup, alignedFrom time.Time, alignedTo time.Time) {
+  width := to.Sub(from) / time.Duration(interval)
+  if width < time.Hour || width % time.Hour != 0 {
+    return NoRollup, from, to
+  }
+
+  alignedTo = HourlyRollup.Truncate(to)
+  alignedFrom = alignedTo.Add(-width * time.Duration(interval))
+
+  rollup = HourlyRollup
+  if width % DailyRollup.Width() == 0 && DailyRollup.Truncate(alignedFrom).Equal(alignedFrom) {
+    rollup = DailyRollup
+  }
+  return
+}
+
+// Returns the buckets of every term between from and to, as
+// Store.TermBuckets does, read from the rollups when the buckets are whole
+// hours.
+func RollupWindowBuckets(store Store, source string, location string, from time.Time, to time.Time, interval int, ngram NgramRange, lang string) (TermBuckets, error) {
+  rollup, alignedFrom, alignedTo := RollupWindow(from, to, interval)
+  if rollup == NoRollup {
+    return store.TermBuckets(source, location, from, to, interval, ngram, lang)
+  }
+  buckets, err := store.RollupTermBuckets(rollup, source, location, alignedFrom, alignedTo, interval, ngram, lang)
+  if err != nil {
+    return nil, err
+  }
+  return shiftRollupBuckets(store, buckets, source, location, nil, alignedFrom, alignedTo, to, interval, ngram, lang, false)
+}
+
+// Returns the buckets of terms per source between from and to, as
+// Store.TermSourceBuckets does, read from the rollups when the buckets are
+// whole hours.
+func RollupWindowSourceBuckets(store Store, source string, location string, terms []string, from time.Time, to time.Time, interval int) (TermBuckets, error) {
+  rollup, alignedFrom, alignedTo := RollupWindow(from, to, interval)
+  if rollup == NoRollup {
+    return store.TermSourceBuckets(source, location, terms, from, to, interval)
+  }
+  buckets, err := store.RollupTermSourceBuckets(rollup, source, location, terms, alignedFrom, alignedTo, interval)
+  if err != nil {
+    return nil, err
+  }
+  return shiftRollupBuckets(store, buckets, source, location, terms, alignedFrom, alignedTo, to, interval, AnyNgram, "", true)
+}
+
+// Moves buckets read from a rollup for the window rounded down to the hour
+// forward to the window ending at to. Each bucket loses the raw terms of
+// the partial hour it starts with and gains those of the partial hour after
+// it, so the newest bucket holds a full width of posts rather than the
+// rollup's last whole hours, or a part of an hour still to come.
+func shiftRollupBuckets(store Store, buckets TermBuckets, source string, location string, terms []string, alignedFrom time.Time, alignedTo time.Time, to time.Time, interval int, ngram NgramRange, lang string, bySource bool) (TermBuckets, error) {
+  width := alignedTo.Sub(alignedFrom) / time.Duration(interval)
+  partial := to.Sub(alignedTo)
+  if partial == 0 {
+    return buckets, nil
+  }
+
+  // Slice k is the partial hour starting the kth bucket of the rollup
+  slices, err := store.TermSliceBuckets(source, location, terms, alignedFrom, width, interval + 1, partial, ngram, lang)
+  if err != nil {
+    return nil, err
+  }
+
+  type key struct {
+    term string
+    source string
+    bucket int
+  }
+  index := map[key]int {}
+  for i, bucket := range buckets {
+    index[key{bucket.Term, bucket.Source, bucket.Bucket}] = i
+  }
+  add := func(slice TermBucket, bucket int, sign int) {
+    k := key{slice.Term, "", bucket}
+    if bySource {
+      k.source = slice.Source
+    }
+    if _, ok := index[k]; !ok {
+      index[k] = len(buckets)
+      buckets = append(buckets, TermBucket {Term: k.term, Source: k.source, Bucket: bucket})
+    }
+    buckets[index[k]].Occurrences += sign * slice.Occurrences
+    buckets[index[k]].Mentions += sign * slice.Mentions
+  }
+  for _, slice := range slices {
+    if slice.Bucket < interval {
+      add(slice, slice.Bucket, -1)
+    }
+    if slice.Bucket > 0 {
+      add(slice, slice.Bucket - 1, 1)
+    }
+  }
+
+  shifted := TermBuckets {}
+  for _, bucket := range buckets {
+    if bucket.Mentions > 0 {
+      shifted = append(shifted, bucket)
+    }
+  }
+  return shifted, nil
+}
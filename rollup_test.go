@@ -0,0 +1,144 @@
+package main
+
+import (
+  "reflect"
+  "sort"
+  "strconv"
+  "testing"
+  "time"
+)
+
+func TestRollupWindow(t *testing.T) {
+  day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+  tests := []struct {
+    name string
+    from time.Time
+    to time.Time
+    interval int
+    rollup Rollup
+    alignedFrom time.Time
+    alignedTo time.Time
+  }{
+    {"hours rounded down", day.Add(10 * time.Hour + 25 * time.Minute), day.Add(16 * time.Hour + 25 * time.Minute), 3, HourlyRollup, day.Add(10 * time.Hour), day.Add(16 * time.Hour)},
+    {"hours on the hour", day.Add(10 * time.Hour), day.Add(16 * time.Hour), 6, HourlyRollup, day.Add(10 * time.Hour), day.Add(16 * time.Hour)},
+    {"whole days", day.Add(-48 * time.Hour), day, 2, DailyRollup, day.Add(-48 * time.Hour), day},
+    {"days rounded down", day.Add(-24 * time.Hour + 30 * time.Minute), day.Add(30 * time.Minute), 1, DailyRollup, day.Add(-24 * time.Hour), day},
+    {"days off midnight", day.Add(-21 * time.Hour), day.Add(3 * time.Hour), 1, HourlyRollup, day.Add(-21 * time.Hour), day.Add(3 * time.Hour)},
+    {"part hours", day, day.Add(3 * time.Hour), 2, NoRollup, day, day.Add(3 * time.Hour)},
+    {"under an hour", day, day.Add(time.Hour), 2, NoRollup, day, day.Add(time.Hour)},
+  }
+  for _, test := range tests {
+    rollup, alignedFrom, alignedTo := RollupWindow(test.from, test.to, test.interval)
+    if rollup != test.rollup || !alignedFrom.Equal(test.alignedFrom) || !alignedTo.Equal(test.alignedTo) {
+      t.Errorf("%s: RollupWindow = %q %v - %v, want %q %v - %v", test.name, rollup, alignedFrom, alignedTo, test.rollup, test.alignedFrom, test.alignedTo)
+    }
+  }
+}
+
+// Posts spread unevenly over the minutes of several hours, so that a window
+// ending part way through an hour splits some of them from their rollup rows
+func rollupTestStore(t *testing.T) *MemoryStore {
+  posts := []PostWithTerms {}
+  start := testNow.Add(-8 * time.Hour)
+  for i := 0; i < 48; i++ {
+    posted := start.Add(time.Duration(i * i % 480) * time.Minute)
+    source := "twitter"
+    if i % 3 == 0 {
+      source = "rss"
+    }
+    terms := map[string]int {"jobs": 1 + i % 2}
+    if i % 4 == 0 {
+      terms["robot"] = 2
+    }
+    posts = append(posts, testPost("http://t/" + strconv.Itoa(i), "nairobi", source, posted, terms))
+  }
+  return newTestStore(t, posts...)
+}
+
+func sortBuckets(buckets TermBuckets) TermBuckets {
+  sort.Slice(buckets, func(i, j int) bool {
+    a, b := buckets[i], buckets[j]
+    if a.Bucket != b.Bucket {
+      return a.Bucket < b.Bucket
+    }
+    if a.Term != b.Term {
+      return a.Term < b.Term
+    }
+    return a.Source < b.Source
+  })
+  return buckets
+}
+
+func TestRollupWindowBucketsMatchRawTerms(t *testing.T) {
+  store := rollupTestStore(t)
+  for _, window := range []struct {
+    from time.Time
+    to time.Time
+    interval int
+  }{
+    {testNow.Add(-6 * time.Hour), testNow, 3},
+    {testNow.Add(-6 * time.Hour), testNow, 6},
+    {testNow.Add(-6 * time.Hour - 25 * time.Minute), testNow.Add(-25 * time.Minute), 2},
+  } {
+    rollup, _, _ := RollupWindow(window.from, window.to, window.interval)
+    if rollup == NoRollup {
+      t.Fatalf("window %v - %v / %d isn't read from a rollup", window.from, window.to, window.interval)
+    }
+
+    want, _ := store.TermBuckets("", "nairobi", window.from, window.to, window.interval, AnyNgram, "")
+    got, err := RollupWindowBuckets(store, "", "nairobi", window.from, window.to, window.interval, AnyNgram, "")
+    if err != nil {
+      t.Fatalf("RollupWindowBuckets: %v", err)
+    }
+    if !reflect.DeepEqual(sortBuckets(got), sortBuckets(want)) {
+      t.Errorf("%v - %v / %d: RollupWindowBuckets = %v, want %v", window.from, window.to, window.interval, got, want)
+    }
+
+    terms := []string {"jobs", "robot"}
+    want, _ = store.TermSourceBuckets("", "nairobi", terms, window.from, window.to, window.interval)
+    got, err = RollupWindowSourceBuckets(store, "", "nairobi", terms, window.from, window.to, window.interval)
+    if err != nil {
+      t.Fatalf("RollupWindowSourceBuckets: %v", err)
+    }
+    if !reflect.DeepEqual(sortBuckets(got), sortBuckets(want)) {
+      t.Errorf("%v - %v / %d: RollupWindowSourceBuckets = %v, want %v", window.from, window.to, window.interval, got, want)
+    }
+  }
+}
+
+func TestTermBucketsAreHalfOpen(t *testing.T) {
+  hour := testNow.Truncate(time.Hour)
+  store := newTestStore(t,
+    testPost("http://t/1", "nairobi", "twitter", hour.Add(-2 * time.Hour), map[string]int {"jobs": 1}),
+    testPost("http://t/2", "nairobi", "twitter", hour.Add(-time.Hour), map[string]int {"jobs": 2}),
+    testPost("http://t/3", "nairobi", "twitter", hour, map[string]int {"jobs": 4}),
+  )
+
+  // A post on the hour ending one window starts the next, in the raw terms
+  // as in the rollup
+  for _, window := range []struct {
+    from time.Time
+    to time.Time
+    want TermBuckets
+  }{
+    {hour.Add(-2 * time.Hour), hour, TermBuckets {{Term: "jobs", Bucket: 0, Occurrences: 1, Mentions: 1}, {Term: "jobs", Bucket: 1, Occurrences: 2, Mentions: 1}}},
+    {hour, hour.Add(2 * time.Hour), TermBuckets {{Term: "jobs", Bucket: 0, Occurrences: 4, Mentions: 1}}},
+  } {
+    raw, _ := store.TermBuckets("", "nairobi", window.from, window.to, 2, AnyNgram, "")
+    if !reflect.DeepEqual(sortBuckets(raw), window.want) {
+      t.Errorf("%v - %v: TermBuckets = %v, want %v", window.from, window.to, raw, window.want)
+    }
+    rolled, err := RollupWindowBuckets(store, "", "nairobi", window.from, window.to, 2, AnyNgram, "")
+    if err != nil {
+      t.Fatalf("RollupWindowBuckets: %v", err)
+    }
+    if !reflect.DeepEqual(sortBuckets(rolled), window.want) {
+      t.Errorf("%v - %v: RollupWindowBuckets = %v, want %v", window.from, window.to, rolled, window.want)
+    }
+  }
+
+  count, _ := store.CountPosts("", "nairobi", nil, hour.Add(-2 * time.Hour), hour)
+  if count != 2 {
+    t.Errorf("CountPosts = %d, want the post at the end of the window left out", count)
+  }
+}
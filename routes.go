@@ -15,10 +15,10 @@ type Route struct {
 
 type Routes []Route
 
-func NewRouter() *mux.Router {
+func NewRouter(engine *Engine) *mux.Router {
 
     router := mux.NewRouter().StrictSlash(true)
-    for _, route := range routes {
+    for _, route := range engine.Routes() {
         router.
             Methods(route.Method).
             Path(route.Pattern).
@@ -29,179 +29,295 @@ func NewRouter() *mux.Router {
     return router
 }
 
-var routes = Routes{
-    Route{
-        "Swagger",
-        "GET",
-        "/v1/swagger.json",
-        Swagger,
-    },
-    Route{
-        "Index",
-        "GET",
-        "/",
-        Index,
-    },
-    Route{
-        "Locations",
-        "GET",
-        "/v1/locations",
-        RenderLocationsJSON,
-    },
-    Route{
-        "LocationStats",
-        "GET",
-        "/v1/locations/{location}/stats",
-        RenderLocationStatsJSON,
-    },
-    Route{
-        "TrendsIndex",
-        "GET",
-        "/v1/locations/{location}/trends/{term}",
-        TrendsIndex,
-    },
-    Route{
-        "TrendSourcesCSV",
-        "GET",
-        "/v1/locations/{location}/trends/{term}/csv",
-        TrendSourcesCSV,
-    },
-    Route{
-        "TrendsRootIndex",
-        "GET",
-        "/v1/locations/{location}/trends",
-        TrendsRootIndex,
-    },
-    Route{
-        "WebTrendsIndex",
-        "GET",
-        "/web/trends/{location}",
-        WebTrendsRouteIndex,
-    },
-    Route{
-        "WebTrendsIndex",
-        "GET",
-        "/web/trends/{location}/{term}",
-        WebTrendsIndex,
-    },
-    Route{
-        "WebStats",
-        "GET",
-        "/web/stats",
-        WebStats,
-    },
-    Route{
-        "AdminLogin",
-        "GET",
-        "/admin/login",
-        AdminLogin,
-    },
-    Route{
-        "AdminLogin",
-        "POST",
-        "/admin/login",
-        AdminLogin,
-    },
-    Route{
-        "AdminLogout",
-        "GET",
-        "/admin/logout",
-        AdminLogout,
-    },
-    Route{
-        "AdminIndex",
-        "GET",
-        "/admin/",
-        AdminIndex,
-    },
-    Route{
-        "AdminBuildDatabase",
-        "GET",
-        "/admin/builddatabase",
-        AdminBuildDatabase,
-    },
-    Route{
-        "AdminCreateIndexes",
-        "GET",
-        "/admin/createindexes",
-        AdminCreateIndexes,
-    },
-    Route{
-        "AdminAddStopwords",
-        "GET",
-        "/admin/addstopwords",
-        AdminAddStopwords,
-    },
-    Route{
-        "AdminClearData",
-        "GET",
-        "/admin/cleardata",
-        AdminClearData,
-    },
-    Route{
-        "AdminMiners",
-        "GET",
-        "/admin/miners",
-        AdminMiners,
-    },
-    Route{
-        "AdminResetMinersDatabase",
-        "GET",
-        "/admin/miners/resetdatabase",
-        AdminMinersResetDatabase,
-    },
-    Route{
-        "AdminNewMiner",
-        "GET",
-        "/admin/miners/new",
-        AdminNewMiner,
-    },
-    Route{
-        "AdminCreateMiner",
-        "POST",
-        "/admin/miners",
-        AdminCreateMiner,
-    },
-    Route{
-        "AdminEditMiner",
-        "GET",
-        "/admin/miners/{uid}/edit",
-        AdminEditMiner,
-    },
-    Route{
-        "AdminUpdateMiner",
-        "PATCH",
-        "/admin/miners/{uid}",
-        AdminUpdateMiner,
-    },
-    Route{
-        "AdminUpdateMiner",
-        "PUT",
-        "/admin/miners/{uid}",
-        AdminUpdateMiner,
-    },
-    Route{
-        "AdminUpdateMiner",
-        "POST",
-        "/admin/miners/{uid}/update",
-        AdminUpdateMiner,
-    },
-    Route{
-        "AdminDeleteMiner",
-        "POST",
-        "/admin/miners/{uid}",
-        AdminDeleteMiner,
-    },
-    Route{
-        "AdminDeleteMiner",
-        "DELETE",
-        "/admin/miners/{uid}",
-        AdminDeleteMiner,
-    },
-    Route{
-        "MinerPost",
-        "POST",
-        "/v1/minerpost",
-        MinerPost,
-    },
+func (e *Engine) Routes() Routes {
+    return Routes{
+        Route{
+            "Swagger",
+            "GET",
+            "/v1/swagger.json",
+            Swagger,
+        },
+        Route{
+            "Index",
+            "GET",
+            "/",
+            e.Index,
+        },
+        Route{
+            "Locations",
+            "GET",
+            "/v1/locations",
+            e.RenderLocationsJSON,
+        },
+        Route{
+            "LocationStats",
+            "GET",
+            "/v1/locations/{location}/stats",
+            e.RenderLocationStatsJSON,
+        },
+        Route{
+            "TrendsIndex",
+            "GET",
+            "/v1/locations/{location}/trends/{term}",
+            e.TrendsIndex,
+        },
+        Route{
+            "TrendSourcesCSV",
+            "GET",
+            "/v1/locations/{location}/trends/{term}/csv",
+            e.TrendSourcesCSV,
+        },
+        Route{
+            "TrendForecast",
+            "GET",
+            "/v1/locations/{location}/trends/{term}/forecast",
+            e.TrendForecast,
+        },
+        Route{
+            "TrendsRootIndex",
+            "GET",
+            "/v1/locations/{location}/trends",
+            e.TrendsRootIndex,
+        },
+        Route{
+            "EmergingTermsIndex",
+            "GET",
+            "/v1/locations/{location}/emerging",
+            e.EmergingTermsIndex,
+        },
+        Route{
+            "TopicsIndex",
+            "GET",
+            "/v1/locations/{location}/topics",
+            e.TopicsIndex,
+        },
+        Route{
+            "NetworkIndex",
+            "GET",
+            "/v1/locations/{location}/network",
+            e.NetworkIndex,
+        },
+        Route{
+            "TermCompare",
+            "GET",
+            "/v1/trends/{term}/compare",
+            e.TermCompare,
+        },
+        Route{
+            "TermDiffusionIndex",
+            "GET",
+            "/v1/trends/{term}/diffusion",
+            e.TermDiffusionIndex,
+        },
+        Route{
+            "TermChartIndex",
+            "GET",
+            "/v1/locations/{location}/compare",
+            e.TermChartIndex,
+        },
+        Route{
+            "WebTrendsIndex",
+            "GET",
+            "/web/trends/{location}",
+            e.WebTrendsRouteIndex,
+        },
+        Route{
+            "WebTrendsIndex",
+            "GET",
+            "/web/trends/{location}/{term}",
+            e.WebTrendsIndex,
+        },
+        Route{
+            "WebTermChart",
+            "GET",
+            "/web/compare/{location}",
+            e.WebTermChart,
+        },
+        Route{
+            "WebStats",
+            "GET",
+            "/web/stats",
+            e.WebStats,
+        },
+        Route{
+            "AdminLogin",
+            "GET",
+            "/admin/login",
+            AdminLogin,
+        },
+        Route{
+            "AdminLogin",
+            "POST",
+            "/admin/login",
+            AdminLogin,
+        },
+        Route{
+            "AdminLogout",
+            "GET",
+            "/admin/logout",
+            AdminLogout,
+        },
+        Route{
+            "AdminIndex",
+            "GET",
+            "/admin/",
+            e.AdminIndex,
+        },
+        Route{
+            "AdminBuildDatabase",
+            "GET",
+            "/admin/builddatabase",
+            e.AdminBuildDatabase,
+        },
+        Route{
+            "AdminClearData",
+            "GET",
+            "/admin/cleardata",
+            e.AdminClearData,
+        },
+        Route{
+            "AdminMiners",
+            "GET",
+            "/admin/miners",
+            e.AdminMiners,
+        },
+        Route{
+            "AdminResetMinersDatabase",
+            "GET",
+            "/admin/miners/resetdatabase",
+            e.AdminMinersResetDatabase,
+        },
+        Route{
+            "AdminNewMiner",
+            "GET",
+            "/admin/miners/new",
+            AdminNewMiner,
+        },
+        Route{
+            "AdminCreateMiner",
+            "POST",
+            "/admin/miners",
+            e.AdminCreateMiner,
+        },
+        Route{
+            "AdminEditMiner",
+            "GET",
+            "/admin/miners/{uid}/edit",
+            e.AdminEditMiner,
+        },
+        Route{
+            "AdminUpdateMiner",
+            "PATCH",
+            "/admin/miners/{uid}",
+            e.AdminUpdateMiner,
+        },
+        Route{
+            "AdminUpdateMiner",
+            "PUT",
+            "/admin/miners/{uid}",
+            e.AdminUpdateMiner,
+        },
+        Route{
+            "AdminUpdateMiner",
+            "POST",
+            "/admin/miners/{uid}/update",
+            e.AdminUpdateMiner,
+        },
+        Route{
+            "AdminDeleteMiner",
+            "POST",
+            "/admin/miners/{uid}",
+            e.AdminDeleteMiner,
+        },
+        Route{
+            "AdminDeleteMiner",
+            "DELETE",
+            "/admin/miners/{uid}",
+            e.AdminDeleteMiner,
+        },
+        Route{
+            "AdminRotateMinerSecret",
+            "POST",
+            "/admin/miners/{uid}/rotatesecret",
+            e.AdminRotateMinerSecret,
+        },
+        Route{
+            "AdminStopwords",
+            "GET",
+            "/admin/stopwords",
+            e.AdminStopwords,
+        },
+        Route{
+            "AdminCreateStopwords",
+            "POST",
+            "/admin/stopwords",
+            e.AdminCreateStopwords,
+        },
+        Route{
+            "AdminDeleteStopword",
+            "POST",
+            "/admin/stopwords/{uid}",
+            e.AdminDeleteStopword,
+        },
+        Route{
+            "AdminDeleteStopword",
+            "DELETE",
+            "/admin/stopwords/{uid}",
+            e.AdminDeleteStopword,
+        },
+        Route{
+            "AdminAliases",
+            "GET",
+            "/admin/aliases",
+            e.AdminAliases,
+        },
+        Route{
+            "AdminCreateAliases",
+            "POST",
+            "/admin/aliases",
+            e.AdminCreateAliases,
+        },
+        Route{
+            "AdminDeleteAlias",
+            "POST",
+            "/admin/aliases/{uid}",
+            e.AdminDeleteAlias,
+        },
+        Route{
+            "AdminDeleteAlias",
+            "DELETE",
+            "/admin/aliases/{uid}",
+            e.AdminDeleteAlias,
+        },
+        Route{
+            "Stopwords",
+            "GET",
+            "/v1/stopwords",
+            e.StopwordsIndex,
+        },
+        Route{
+            "StopwordsCreate",
+            "POST",
+            "/v1/stopwords",
+            e.StopwordsCreate,
+        },
+        Route{
+            "StopwordsDelete",
+            "DELETE",
+            "/v1/stopwords/{uid}",
+            e.StopwordsDelete,
+        },
+        Route{
+            "MinerPost",
+            "POST",
+            "/v1/minerpost",
+            e.MinerPost,
+        },
+        Route{
+            "MinerPostStatus",
+            "GET",
+            "/v1/minerpost/{batchId}",
+            e.MinerPostStatus,
+        },
+    }
 }
\ No newline at end of file
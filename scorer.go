@@ -0,0 +1,89 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "sort"
+  "strings"
+)
+
+// A Scorer rates how strongly a term is bursting in the last interval of
+// its series, compared to a baseline drawn from the intervals before it.
+// Trends are ranked by the score, highest first.
+type Scorer interface {
+  Score(series []int) Score
+}
+
+// A term's burst score and the baseline it was measured against, in
+// occurrences per interval.
+type Score struct {
+  Value float64
+  Baseline float64
+}
+
+// The scorers available to the algorithm query parameter, by name. Add an
+// algorithm by registering its Scorer here.
+var Scorers = map[string]Scorer{
+  "velocity": VelocityScorer{},
+  "zscore": ZScoreScorer{},
+  "ewma": EWMAScorer{Alpha: 0.3},
+  "kleinberg": KleinbergScorer{Scaling: 2, Gamma: 1},
+}
+
+// The algorithm used when none is asked for
+const DefaultAlgorithm = "velocity"
+
+// Returns the scorer for the algorithm query parameter, or the default
+// scorer for "".
+func ScorerFor(algorithm string) (Scorer, error) {
+  if algorithm == "" {
+    algorithm = DefaultAlgorithm
+  }
+  scorer, ok := Scorers[strings.ToLower(algorithm)]
+  if !ok {
+    algorithms := []string {}
+    for name := range Scorers {
+      algorithms = append(algorithms, name)
+    }
+    sort.Strings(algorithms)
+    return nil, fmt.Errorf("Unknown algorithm %q, available: %s", algorithm, strings.Join(algorithms, ", "))
+  }
+  return scorer, nil
+}
+
+// Splits a series into its last interval and the trailing intervals
+// before it. A series of one interval has an empty baseline.
+func splitSeries(series []int) (current float64, baseline []float64) {
+  if len(series) == 0 {
+    return 0, nil
+  }
+  for _, count := range series[:len(series) - 1] {
+    baseline = append(baseline, float64(count))
+  }
+  return float64(series[len(series) - 1]), baseline
+}
+
+// The spread used to scale a deviation from mean when the baseline's own
+// spread is zero or it is too short to have one: that of a Poisson count
+// with the same mean, and at least 1.
+func countDeviation(deviation float64, mean float64) float64 {
+  if deviation > 0 {
+    return deviation
+  }
+  return math.Sqrt(math.Max(mean, 1))
+}
+
+// The original ranking: the change from the previous interval as a
+// proportion of it, or the last interval's count when the previous one is
+// empty or there is none. It ranks a term going from 1 to 3 mentions above
+// one going from 100 to 250.
+type VelocityScorer struct{}
+
+func (VelocityScorer) Score(series []int) Score {
+  current, baseline := splitSeries(series)
+  if len(baseline) == 0 || baseline[len(baseline) - 1] == 0 {
+    return Score{Value: current}
+  }
+  previous := baseline[len(baseline) - 1]
+  return Score{Value: (current - previous) / previous, Baseline: previous}
+}
\ No newline at end of file
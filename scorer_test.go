@@ -0,0 +1,73 @@
+package main
+
+import (
+  "math"
+  "testing"
+  "time"
+)
+
+func TestScorers(t *testing.T) {
+  tests := []struct {
+    name string
+    scorer Scorer
+    series []int
+    value float64
+    baseline float64
+  }{
+    {"velocity rise", VelocityScorer{}, []int {1, 2, 4}, 1, 2},
+    {"velocity from nothing", VelocityScorer{}, []int {0, 3}, 3, 0},
+    {"velocity single interval", VelocityScorer{}, []int {5}, 5, 0},
+    {"zscore", ZScoreScorer{}, []int {1, 3, 1, 3, 6}, 4, 2},
+    {"zscore flat baseline", ZScoreScorer{}, []int {2, 2, 2, 5}, 3 / math.Sqrt2, 2},
+    {"zscore single interval", ZScoreScorer{}, []int {4}, 4, 0},
+    {"ewma flat baseline", EWMAScorer{Alpha: 0.5}, []int {2, 2, 2, 5}, 3 / math.Sqrt2, 2},
+    {"ewma", EWMAScorer{Alpha: 0.5}, []int {0, 4, 10}, (10 - 2) / 2.0, 2},
+    {"kleinberg no counts", KleinbergScorer{Scaling: 2, Gamma: 1}, []int {0, 0, 0}, 0, 0},
+    {"kleinberg steady", KleinbergScorer{Scaling: 2, Gamma: 1}, []int {3, 3, 3, 3}, 0, 3},
+  }
+  for _, test := range tests {
+    score := test.scorer.Score(test.series)
+    if math.Abs(score.Value - test.value) > 1e-9 || math.Abs(score.Baseline - test.baseline) > 1e-9 {
+      t.Errorf("%s: Score(%v) = %+v, want %v against %v", test.name, test.series, score, test.value, test.baseline)
+    }
+  }
+}
+
+func TestScorersRankBurstsFirst(t *testing.T) {
+  steady := []int {10, 10, 10, 10, 10, 10, 10, 10}
+  burst := []int {10, 10, 10, 10, 10, 10, 10, 40}
+  for name, scorer := range Scorers {
+    if scorer.Score(burst).Value <= scorer.Score(steady).Value {
+      t.Errorf("%s scores a burst %v no higher than steady counts %v", name, scorer.Score(burst), scorer.Score(steady))
+    }
+  }
+
+  // A burst in the last interval weighs more the longer it lasts
+  kleinberg := KleinbergScorer{Scaling: 2, Gamma: 1}
+  short := kleinberg.Score([]int {1, 1, 1, 1, 1, 1, 1, 1, 1, 20})
+  long := kleinberg.Score([]int {1, 1, 1, 1, 1, 1, 1, 1, 20, 20})
+  if short.Value <= 0 || long.Value <= short.Value {
+    t.Errorf("kleinberg scores a one interval burst %v and a two interval one %v", short.Value, long.Value)
+  }
+}
+
+func TestScorerFor(t *testing.T) {
+  scorer, err := ScorerFor("")
+  if err != nil || scorer != Scorers[DefaultAlgorithm] {
+    t.Errorf("ScorerFor(\"\") = %v, %v, want the default scorer", scorer, err)
+  }
+  if _, err := ScorerFor("ZScore"); err != nil {
+    t.Errorf("ScorerFor(ZScore): %v", err)
+  }
+  if _, err := ScorerFor("tfidf"); err == nil {
+    t.Errorf("ScorerFor accepted an unknown algorithm")
+  }
+}
+
+func TestWordCountRootCollectionRejectsUnknownAlgorithm(t *testing.T) {
+  store := newTestStore(t)
+  _, err := WordCountRootCollection(store, "nairobi", "", testParam(testNow.Add(-time.Hour)), testParam(testNow), 2, 10, UnigramsOnly, nil, "", "tfidf")
+  if _, ok := err.(ValidationError); !ok {
+    t.Errorf("WordCountRootCollection with an unknown algorithm = %v, want a ValidationError", err)
+  }
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+  "math"
+)
+
+// The log-likelihood ratio above which a difference in rates is
+// significant at p < 0.05 (the chi-squared critical value, one degree of
+// freedom)
+const SignificantLogLikelihood = 3.84
+
+// Dunning's log-likelihood ratio (G²) of a term counted a times out of
+// total c in one sample and b times out of total d in another. It is
+// large when the term's rate differs between the samples by more than
+// chance would explain, whichever sample it is higher in.
+func logLikelihood(a float64, b float64, c float64, d float64) float64 {
+  if c + d == 0 || a + b == 0 {
+    return 0
+  }
+  expectedA := c * (a + b) / (c + d)
+  expectedB := d * (a + b) / (c + d)
+  return 2 * (xLogRatio(a, expectedA) + xLogRatio(b, expectedB))
+}
+
+// x ln(x / expected), taking 0 ln 0 as 0
+func xLogRatio(x float64, expected float64) float64 {
+  if x == 0 || expected == 0 {
+    return 0
+  }
+  return x * math.Log(x / expected)
+}
\ No newline at end of file
@@ -39,4 +39,14 @@ func sortedKeys(m map[string]int) []string {
 	}
 	sort.Sort(sm)
 	return sm.s
+}
+
+// keys of a map of series in alphabetical order.
+func sortedSeriesKeys(m map[string][]int) []string {
+	s := make([]string, 0, len(m))
+	for key := range m {
+		s = append(s, key)
+	}
+	sort.Strings(s)
+	return s
 }
\ No newline at end of file
@@ -10,6 +10,9 @@ type Source struct {
   SourceURI string `json:"source_uri"`
   Posted time.Time `json:"posted"`
   Mined time.Time `json:"mined"`
+  // The component terms the post used, when the term has aliases, or the
+  // terms of a topic it used
+  Terms []string `json:"terms,omitempty"`
 }
 
 type Sources []Source
\ No newline at end of file
@@ -0,0 +1,50 @@
+package main
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+)
+
+// A Stemmer reduces a lowercase word to its stem, so that inflections such
+// as "robot" and "robots" can be counted as one term.
+type Stemmer interface {
+  Stem(word string) string
+}
+
+// The stemmers available to the stem query parameter, by language code.
+// Add a language by registering its Stemmer here.
+var Stemmers = map[string]Stemmer{
+  "en": PorterStemmer{},
+}
+
+// Returns the stemmer for the stem query parameter: a language code, or
+// "" for no stemming.
+func StemmerFor(language string) (Stemmer, error) {
+  if language == "" {
+    return nil, nil
+  }
+  stemmer, ok := Stemmers[strings.ToLower(language)]
+  if !ok {
+    languages := []string {}
+    for code := range Stemmers {
+      languages = append(languages, code)
+    }
+    sort.Strings(languages)
+    return nil, fmt.Errorf("No stemmer for %q, available: %s", language, strings.Join(languages, ", "))
+  }
+  return stemmer, nil
+}
+
+// Stems each word of a term, so phrases group by the stems of their words.
+// A nil stemmer leaves the term unchanged.
+func StemTerm(stemmer Stemmer, term string) string {
+  if stemmer == nil {
+    return term
+  }
+  words := strings.Fields(term)
+  for i, word := range words {
+    words[i] = stemmer.Stem(word)
+  }
+  return strings.Join(words, " ")
+}
\ No newline at end of file
@@ -0,0 +1,68 @@
+package main
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+)
+
+// Stopword scopes. A stopword applies to every trend (global), to one
+// location or source (target is its name), or to one miner's posts (target
+// is the miner's id).
+const (
+  StopwordGlobal = "global"
+  StopwordLocation = "location"
+  StopwordSource = "source"
+  StopwordMiner = "miner"
+)
+
+var StopwordScopes = []string{StopwordGlobal, StopwordLocation, StopwordSource, StopwordMiner}
+
+type Stopword struct {
+  Uid int `json:"id"`
+  Word string `json:"word"`
+  Scope string `json:"scope"`
+  Target string `json:"target,omitempty"`
+}
+
+type Stopwords []Stopword
+
+// Builds the stopwords for a list of words in one scope, lowercasing and
+// trimming them and skipping blanks and repeats. Fails if the scope is
+// unknown or its target is missing.
+func NewStopwords(scope string, target string, words []string) (stopwords Stopwords, err error) {
+  scope = strings.ToLower(strings.TrimSpace(scope))
+  target = strings.TrimSpace(target)
+  switch scope {
+  case StopwordGlobal:
+    target = ""
+  case StopwordLocation, StopwordSource:
+    if target == "" {
+      return nil, fmt.Errorf("A %s stopword needs the %s it applies to", scope, scope)
+    }
+  case StopwordMiner:
+    if _, err := strconv.Atoi(target); err != nil {
+      return nil, fmt.Errorf("A miner stopword needs the id of the miner it applies to, got %q", target)
+    }
+  default:
+    return nil, fmt.Errorf("Unknown stopword scope %q, expected one of %s", scope, strings.Join(StopwordScopes, ", "))
+  }
+
+  seen := map[string]bool {}
+  stopwords = Stopwords {}
+  for _, word := range words {
+    word = strings.ToLower(strings.TrimSpace(word))
+    if word == "" || seen[word] {
+      continue
+    }
+    seen[word] = true
+    stopwords = append(stopwords, Stopword{Word: word, Scope: scope, Target: target})
+  }
+  return
+}
+
+// Splits an imported list of stopwords or aliases, one per line or comma
+// separated.
+func ParseTermList(list string) []string {
+  return SplitStopwords(strings.Replace(strings.Replace(list, "\r", "", -1), "\n", ",", -1))
+}
\ No newline at end of file
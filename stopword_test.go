@@ -0,0 +1,85 @@
+package main
+
+import (
+  "reflect"
+  "sort"
+  "strconv"
+  "testing"
+  "time"
+)
+
+func TestStopwordsForScopes(t *testing.T) {
+  store := NewMemoryStore()
+  store.InsertMiner(Miner {Location: "nairobi", Source: "twitter"})
+  lagos, _ := store.InsertMiner(Miner {Location: "lagos", Source: "rss"})
+  for _, scoped := range []struct {
+    scope string
+    target string
+    word string
+  }{
+    {StopwordGlobal, "", "rt"},
+    {StopwordLocation, "nairobi", "kenya"},
+    {StopwordSource, "rss", "feed"},
+    {StopwordMiner, strconv.Itoa(lagos), "naija"},
+  } {
+    stopwords, err := NewStopwords(scoped.scope, scoped.target, []string {scoped.word})
+    if err != nil {
+      t.Fatalf("NewStopwords(%s): %v", scoped.scope, err)
+    }
+    store.InsertStopwords(stopwords)
+  }
+
+  tests := []struct {
+    location string
+    source string
+    want []string
+  }{
+    {"nairobi", "twitter", []string {"kenya", "rt"}},
+    {"lagos", "rss", []string {"feed", "naija", "rt"}},
+    {"lagos", "twitter", []string {"rt"}},
+    {"all", "all", []string {"feed", "kenya", "naija", "rt"}},
+  }
+  for _, test := range tests {
+    got, err := store.StopwordsFor(test.location, test.source)
+    if err != nil {
+      t.Fatalf("StopwordsFor(%s, %s): %v", test.location, test.source, err)
+    }
+    sort.Strings(got)
+    if !reflect.DeepEqual(got, test.want) {
+      t.Errorf("StopwordsFor(%s, %s) = %v, want %v", test.location, test.source, got, test.want)
+    }
+  }
+
+  if _, err := NewStopwords(StopwordMiner, "lagos", []string {"x"}); err == nil {
+    t.Errorf("NewStopwords accepted a miner stopword without a miner id")
+  }
+}
+
+func TestStopwordsScopeTrends(t *testing.T) {
+  from := testNow.Add(-2 * time.Hour)
+  store := newTestStore(t,
+    testPost("http://t/1", "nairobi", "twitter", testNow.Add(-30 * time.Minute), map[string]int {"kenya": 2, "jobs": 2}),
+    testPost("http://t/2", "lagos", "twitter", testNow.Add(-30 * time.Minute), map[string]int {"kenya": 2, "jobs": 2}),
+  )
+  stopwords, _ := NewStopwords(StopwordLocation, "nairobi", []string {"kenya"})
+  store.InsertStopwords(stopwords)
+
+  terms := func(location string) []string {
+    counts, err := WordCountRootCollection(store, location, "", testParam(from), testParam(testNow), 2, 10, UnigramsOnly, nil, "", "")
+    if err != nil {
+      t.Fatalf("WordCountRootCollection(%s): %v", location, err)
+    }
+    found := []string {}
+    for _, count := range counts {
+      found = append(found, count.Term)
+    }
+    sort.Strings(found)
+    return found
+  }
+  if got := terms("nairobi"); !reflect.DeepEqual(got, []string {"jobs"}) {
+    t.Errorf("nairobi trends = %v, want the location stopword left out", got)
+  }
+  if got := terms("lagos"); !reflect.DeepEqual(got, []string {"jobs", "kenya"}) {
+    t.Errorf("lagos trends = %v, want the nairobi stopword kept", got)
+  }
+}
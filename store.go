@@ -0,0 +1,104 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "time"
+)
+
+// A Store persists miners, posts and terms and answers the aggregate
+// queries the trend collections are built from. PostgresStore is used in
+// production, MemoryStore for tests and local demos.
+type Store interface {
+  // Schema management, see migrations.go
+  SchemaVersion() (int, error)
+  MigrateTo(version int) error
+  ClearData() error
+  ResetMiners() error
+  Close() error
+
+  // Miners
+  Miners() (Miners, error)
+  Miner(uid int) (Miner, error)
+  InsertMiner(miner Miner) (int, error)
+  UpdateMiner(miner Miner) (int64, error)
+  DeleteMiner(uid int) (int64, error)
+  SetMinerKeyHash(uid int, keyHash string) (int64, error)
+
+  // Ingest batches, see ingest.go
+  InsertBatch(batch BatchStatus) error
+  UpdateBatch(batch BatchStatus) error
+  // Returns the status of a batch, or sql.ErrNoRows if there isn't one.
+  Batch(id string) (BatchStatus, error)
+  // Forgets the batches received before a time and returns how many.
+  DeleteBatches(receivedBefore time.Time) (int64, error)
+
+  // Stopwords. A miner's Stopwords field reads and replaces its miner
+  // scoped stopwords.
+  // Returns the words of every stopword that applies to trends for a
+  // location and source: global ones, those for the location or the source,
+  // and those of miners for the location and source. "" matches any.
+  StopwordsFor(location string, source string) ([]string, error)
+  // Lists stopwords by scope and target, "" matching any.
+  Stopwords(scope string, target string) (Stopwords, error)
+  // Adds stopwords, skipping any already present, and returns how many
+  // were added.
+  InsertStopwords(stopwords Stopwords) (int, error)
+  DeleteStopword(uid int) (int64, error)
+
+  // Aliases, see alias.go
+  Aliases() (Aliases, error)
+  // Adds aliases, moving any term already aliased to its new canonical
+  // term, and returns how many were added or moved.
+  InsertAliases(aliases Aliases) (int, error)
+  DeleteAlias(uid int) (int64, error)
+
+  // Posts and terms
+  InsertPosts(posts []PostWithTerms) ([]int, error)
+  PostsCount(location string) (int, error)
+  LastMined(location string) (time.Time, error)
+
+  // Aggregates. Windows run from from up to but not including to, as the
+  // rollup rows do, so a window's buckets join up with the next window's.
+  TermBuckets(source string, location string, from time.Time, to time.Time, interval int, ngram NgramRange, lang string) (TermBuckets, error)
+  // These take the terms counted together as one, such as a canonical term
+  // and its aliases, matched exactly but for case
+  TermSourceBuckets(source string, location string, terms []string, from time.Time, to time.Time, interval int) (TermBuckets, error)
+  TermSources(source string, location string, terms []string, from time.Time, to time.Time, limit int) (Posts, error)
+  // Returns the terms used in at least minSupport of the posts using terms,
+  // with their Occurrences, Mentions and Background, the limit (0 for all)
+  // with the most occurrences first.
+  RelatedTerms(source string, location string, terms []string, from time.Time, to time.Time, minSupport int, limit int) ([]Related, error)
+  // Counts the posts in a window using any of terms, or all posts when
+  // terms is empty.
+  CountPosts(source string, location string, terms []string, from time.Time, to time.Time) (int, error)
+  // Counts the posts in each of interval buckets of a window
+  PostBuckets(source string, location string, from time.Time, to time.Time, interval int) ([]int, error)
+  // Returns each pair of terms used together in the same posts, with the
+  // number of posts using both.
+  CoOccurrences(source string, location string, terms []string, from time.Time, to time.Time) (CoOccurrences, error)
+  // Returns when each of terms was first posted for a location and source,
+  // leaving out terms never posted.
+  FirstSeen(source string, location string, terms []string) (map[string]time.Time, error)
+
+  // Rollups, see rollup.go
+  BackfillRollups() error
+  RollupTermBuckets(rollup Rollup, source string, location string, from time.Time, to time.Time, interval int, ngram NgramRange, lang string) (TermBuckets, error)
+  RollupTermSourceBuckets(rollup Rollup, source string, location string, terms []string, from time.Time, to time.Time, interval int) (TermBuckets, error)
+  // Returns the bucketed word counts of terms per source from the raw terms
+  // of slices slices, the kth covering length from from + k * width. Every
+  // term is counted when terms is empty.
+  TermSliceBuckets(source string, location string, terms []string, from time.Time, width time.Duration, slices int, length time.Duration, ngram NgramRange, lang string) (TermBuckets, error)
+}
+
+// Returns the Store selected by the DATA_STORE environment variable,
+// "postgres" (the default) or "memory".
+func NewStore() (Store, error) {
+  switch os.Getenv("DATA_STORE") {
+  case "", "postgres":
+    return NewPostgresStore(ConnectToDatabase()), nil
+  case "memory":
+    return NewMemoryStore(), nil
+  }
+  return nil, fmt.Errorf("Unknown DATA_STORE %q", os.Getenv("DATA_STORE"))
+}
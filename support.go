@@ -2,12 +2,14 @@ package main
 
 import (
   "fmt"
+  "math"
+  "sort"
   "time"
   "strings"
 )
 
-func BuildLocationsList() (locations Locations, err error) {
-  miners, err := MinersCollection()
+func BuildLocationsList(store Store) (locations Locations, err error) {
+  miners, err := store.Miners()
   locations = Locations{}
   locationsAdded := map[string]Location {}
 
@@ -41,25 +43,26 @@ func stringInSlice(str string, list []string) bool {
 }
 
 
-func CollectStopwords(location string, source string) (stopwords []string) {
-  stoprows, err := QueryStopwordsFor(location, source)
+// Returns the stopwords to leave out of trends for a location, source and
+// language. They are applied when trends are read, so changes to the
+// stopwords also apply to posts already stored.
+func CollectStopwords(store Store, location string, source string, lang string) (stopwords []string) {
+  managed, err := store.StopwordsFor(location, source)
   checkErr(err)
   stopwords = []string{"http"}
-
-  for stoprows.Next() {
-    var stopstr string
-    err := stoprows.Scan(&stopstr)
-    checkErr(err)
-    stopstr   = strings.ToLower(stopstr)
-    stopstr   = strings.Replace(stopstr, " ", "", -1)
-    stopwords = append(stopwords, strings.Split(stopstr, ",")...)
-  }
+  // Posts have their own language's stopwords removed at ingest, whether
+  // sent as text or terms, so a language's list is only applied again when
+  // trends are filtered to it, catching terms stored before that was done
+  stopwords = append(stopwords, BuiltinStopwords(lang)...)
+  stopwords = append(stopwords, managed...)
 
   return stopwords
 }
 
 
-func WordCountRootCollection(location string, source string, fromParam string, toParam string, interval int, limit int) (sortedCounts WordCounts,  collectionErr error) {
+// Ranks the terms of a window by the burst score of their series, scored
+// by the scorer registered as algorithm ("" for the default).
+func WordCountRootCollection(store Store, location string, source string, fromParam string, toParam string, interval int, limit int, ngram NgramRange, stemmer Stemmer, lang string, algorithm string) (sortedCounts WordCounts,  collectionErr error) {
 
   defer func() {
         if r := recover(); r != nil {
@@ -71,110 +74,89 @@ func WordCountRootCollection(location string, source string, fromParam string, t
         }
     }()
 
-  wordCounts := WordCounts {}
-
   if location == "all" {
     location = ""
   }
 
-  t := time.Now()
-  if fromParam == "" {
-    from := t.Add(-24 * time.Hour)
-    fromParam = from.Format("200601021504")
+  if algorithm == "" {
+    algorithm = DefaultAlgorithm
   }
-
-  if toParam == "" {
-    toParam = t.Format("200601021504")
+  scorer, err := ScorerFor(algorithm)
+  if err != nil {
+    return nil, ValidationError{Message: err.Error()}
   }
-
-  fromTime, err := time.Parse("200601021504", fromParam)
+  interval, err = parseInterval(interval, 2)
   if err != nil {
-      fmt.Errorf("invalid from date: %v", err)
+    return nil, err
   }
 
-  toTime, err := time.Parse("200601021504", toParam)
+  _, _, fromTime, toTime, err := parseWindow(fromParam, toParam)
   if err != nil {
-      fmt.Errorf("invalid to date: %v", err)
+    return nil, err
   }
 
-  duration := toTime.Sub(fromTime)
-  duration  = duration / time.Duration(interval)
-
-  stopwords := CollectStopwords(location, source)
-  
-  for i := 0; i < interval; i++ {
-
-    toTime = fromTime.Add(duration)
-    toParam = toTime.Format("200601021504")
-
-    rows, err := QueryTerms(source, location, "", fromParam, toParam)
-    checkErr(err)
-
-    for rows.Next() {
-      var uid int
-      var postid int
-      var term string
-      var wordcount int
-      var posted time.Time
-      var termLocation string
-      var locationHash int
-      var termSource string
-      err := rows.Scan(&uid, &postid, &term, &wordcount, &posted, &termLocation, &locationHash, &termSource)
-      checkErr(err)
-      wordCount := WordCount {
-        Term: term,
-        Occurrences: wordcount,
-        Series: []int{wordcount},
-        Sequence: i,
-      }
-
-      if !strings.ContainsAny(term, "<>[]/:;()=\"") && !stringInSlice(term, stopwords) {
-        wordCounts = append(wordCounts, wordCount)
-      }
-
-    }
-
-    fromTime = fromTime.Add(duration)
-    fromParam = fromTime.Format("200601021504")
+  stopwords := map[string]bool {}
+  for _, word := range CollectStopwords(store, location, source, lang) {
+    stopwords[word] = true
   }
+  aliases, err := store.Aliases()
+  checkErr(err)
+  aliasMap := aliases.Map()
+
+  // Bucketing and per-term totals are done by the store in one query,
+  // against the rollups when the buckets are whole hours
+  buckets, err := RollupWindowBuckets(store, source, location, fromTime, toTime, interval, ngram, lang)
+  checkErr(err)
 
   totalCounts := map[string]int {}
   serieses := map[string][]int {}
+  // Occurrences of each surface form merged into a stem or canonical term
+  forms := map[string]map[string]int {}
 
-  for _, wordcount := range wordCounts {
-    if _, ok := serieses[wordcount.Term]; ok {
-    } else {
-      serieses[wordcount.Term] = make([]int, int(interval))
+  for _, bucket := range buckets {
+    if strings.ContainsAny(bucket.Term, "<>[]/:;()=\"") || stopwords[bucket.Term] {
+      continue
     }
-  }
-
-  for _, wordcount := range wordCounts {
-    count := totalCounts[wordcount.Term]
-    count = count + wordcount.Occurrences
-    totalCounts[wordcount.Term] = count
-    serieses[wordcount.Term][wordcount.Sequence] = serieses[wordcount.Term][wordcount.Sequence] + 1
+    // Aliases are counted under their canonical term, which is not stemmed
+    term := aliasMap.Canonical(bucket.Term)
+    if !aliasMap.Aliased(term) {
+      term = StemTerm(stemmer, term)
+    }
+    if _, ok := serieses[term]; !ok {
+      serieses[term] = make([]int, int(interval))
+      forms[term] = map[string]int {}
+    }
+    totalCounts[term] = totalCounts[term] + bucket.Occurrences
+    serieses[term][bucket.Bucket] = serieses[term][bucket.Bucket] + bucket.Mentions
+    forms[term][bucket.Term] += bucket.Occurrences
   }
 
   velocityCounts := map[string]WordCount {}
 
-  // For ordering by velocity
+  // For ordering by score
   for key, _ := range totalCounts {
     if totalCounts[key] > 1 {
 
-      // Calculate the velocity
-      velocity := 0.0
-      if serieses[key][interval - 2] == 0 {
-        velocity = float64(serieses[key][interval - 1])
-      } else {
-        velocity = (float64(serieses[key][interval - 1]) - float64(serieses[key][interval - 2])) / float64(serieses[key][interval - 2])
-      }
-
-      velocityCounts[key] = WordCount {
+      score := scorer.Score(serieses[key])
+      wordCount := WordCount {
                 Term: key,
+                Ngram: NgramOf(key),
                 Occurrences: totalCounts[key],
                 Series: serieses[key],
-                Velocity: velocity,
+                Velocity: VelocityScorer{}.Score(serieses[key]).Value,
+                Algorithm: strings.ToLower(algorithm),
+                Score: score.Value,
+                Baseline: score.Baseline,
               }
+      if stemmer != nil || aliasMap.Aliased(key) {
+        wordCount.Forms = NewTermForms(forms[key])
+      }
+      if stemmer != nil && !aliasMap.Aliased(key) {
+        wordCount.Stem = key
+        wordCount.Term = wordCount.Forms[0].Term
+        wordCount.Ngram = NgramOf(wordCount.Term)
+      }
+      velocityCounts[key] = wordCount
       }
   }
 
@@ -182,10 +164,10 @@ func WordCountRootCollection(location string, source string, fromParam string, t
 
   sortedCounts = WordCounts {}
   for _, res := range sortedWordCountKeys(velocityCounts) {
-    if _, ok := rankings[velocityCounts[res].Velocity]; ok {
-        rankings[velocityCounts[res].Velocity]++
+    if _, ok := rankings[velocityCounts[res].Score]; ok {
+        rankings[velocityCounts[res].Score]++
       } else {
-        rankings[velocityCounts[res].Velocity] = 1
+        rankings[velocityCounts[res].Score] = 1
       }
 
       if len(rankings) > limit {
@@ -219,178 +201,151 @@ func WordCountRootCollection(location string, source string, fromParam string, t
   return
 }
 
-func MinersCollection() (miners Miners, err error) {
-  miners = Miners {}
-
-  rows, err := QueryMiners()
-  if err != nil {
+// Default and maximum lengths of the Related and Sources lists returned by
+// TrendsCollection.
+const (
+  DefaultRelatedLimit = 50
+  MaxRelatedLimit = 1000
+  DefaultSourcesLimit = 100
+  MaxSourcesLimit = 10000
+)
 
-  } else {
-    for rows.Next() {
-      var uid int
-      var name string
-      var source string
-      var location string
-      var url string
-      var geoCoord Point
-      var locationHash int
-      var stopwords string
-      err := rows.Scan(&uid, &name, &source, &location, &url, &geoCoord, &locationHash, &stopwords)
-      checkErr(err)
-      miner := Miner {
-        Uid: uid,
-        Name: name,
-        Source: source,
-        Location: location,
-        GeoCoord: geoCoord,
-        Url: url,
-        Stopwords: stopwords,
-      }
-      miners = append(miners, miner)
-    }
+// Clamps a requested list length to between 1 and max, using defaultLimit
+// when none was requested.
+func clampLimit(limit int, defaultLimit int, max int) int {
+  if limit < 1 {
+    return defaultLimit
   }
-
-  return
+  if limit > max {
+    return max
+  }
+  return limit
 }
 
-func TrendsCollection(source string, location string, term string, fromParam string, toParam string, interval int, velocityInterval float64, minimumVelocity float64) TermPackage {
+// Builds the TermPackage for a term. The series, sources and related terms
+// are each fetched with a single set-based query, and the related and
+// sources lists are capped at relatedLimit and sourcesLimit. Related terms
+// must share at least relatedMinSupport posts with the term (0 for the
+// scoring's default) and are ranked by relatedScoring, see ScoreRelated. A
+// term with aliases, or an alias, is counted as its canonical term with the
+// component terms listed in Forms.
+func TrendsCollection(store Store, source string, location string, term string, fromParam string, toParam string, interval int, velocityInterval float64, minimumVelocity float64, relatedLimit int, sourcesLimit int, relatedScoring string, relatedMinSupport int) (termPackage TermPackage, collectionErr error) {
+
+  defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            collectionErr, ok = r.(error)
+            if !ok {
+                collectionErr = fmt.Errorf("TrendsCollection: %v", r)
+            }
+        }
+    }()
 
   if location == "all" {
     location = ""
   }
-
-  t := time.Now()
-  if fromParam == "" {
-    from := t.Add(-24 * time.Hour)
-    fromParam = from.Format("200601021504")
-  }
-
-  if toParam == "" {
-    toParam = t.Format("200601021504")
+  interval, err := parseInterval(interval, 2)
+  if err != nil {
+    return termPackage, err
   }
-
-  fromTime, err := time.Parse("200601021504", fromParam)
+  _, _, fromTime, toTime, err := parseWindow(fromParam, toParam)
   if err != nil {
-      fmt.Errorf("invalid from date: %v", err)
+    return termPackage, err
   }
-
-  toTime, err := time.Parse("200601021504", toParam)
+  relatedScoring, err = RelatedScoringFor(relatedScoring)
   if err != nil {
-      fmt.Errorf("invalid to date: %v", err)
+    return termPackage, ValidationError{Message: err.Error()}
   }
 
-  duration := toTime.Sub(fromTime)
-  duration  = duration / time.Duration(interval)
+  resolved, err := termForms(store, term)
+  checkErr(err)
 
-  termPackage := TermPackage {
-    Term: term,
+  termPackage = TermPackage {
+    Term: resolved.Term,
     Series: make([]int, interval),
     Sources: make([]Source, 0),
     SourceTypes: make([]SourceType, 0),
+    Related: make([]Related, 0),
   }
 
-  related := map[string]int {}
-
   totalOccurrences := 0
 
   sourceSerieses := map[string][]int {}
+  forms := map[string]int {}
 
-  sourceURIsAdded := map[string]bool {}
+  buckets, err := RollupWindowSourceBuckets(store, source, location, resolved.Components, fromTime, toTime, interval)
+  checkErr(err)
+  for _, bucket := range buckets {
+    forms[bucket.Term] += bucket.Occurrences
+    termPackage.Series[bucket.Bucket] = termPackage.Series[bucket.Bucket] + bucket.Occurrences
+    totalOccurrences = totalOccurrences + bucket.Occurrences
 
-  for i := 0; i < interval; i++ {
-    toTime = fromTime.Add(duration)
-    toParam = toTime.Format("200601021504")
-    rows, err := QueryTerms(source, location, term, fromParam, toParam)
-    if err != nil {
-    } else {
-      for rows.Next() {
-        var uid int
-        var postid int
-        var term string
-        var wordcount int
-        var posted time.Time
-        var location string
-        var locationHash int
-        var source string
-        err := rows.Scan(&uid, &postid, &term, &wordcount, &posted, &location, &locationHash, &source)
-        checkErr(err)
-        termPackage.Series[i] = termPackage.Series[i] + wordcount
-        totalOccurrences = totalOccurrences + wordcount
-
-        if _, ok := sourceSerieses[source]; ok {
-        } else {
-          sourceSerieses[source] = make([]int, int(interval))
-        }
-        sourceSerieses[source][i] = sourceSerieses[source][i] + wordcount
-
-        postRows := QueryPosts(fmt.Sprintf(" WHERE uid=%d", postid))
-        for postRows.Next() {
-          var thisPostuid int
-          var mined time.Time
-          var postPosted time.Time
-          var sourceURI string
-          var postLocation string
-          var postSource string
-          var postLocationHash int
-          err = postRows.Scan(&thisPostuid, &mined, &postPosted, &sourceURI, &postLocation, &postSource, &postLocationHash)
-          checkErr(err)
-          if _, ok := sourceURIsAdded[sourceURI]; ok {
-          } else {
-            sourceURIsAdded[sourceURI] = true
-            source := Source {
-              Source: postSource,
-              Location: postLocation,
-              SourceURI: sourceURI,
-              Posted: postPosted,
-              Mined: mined,
-            }
-            termPackage.Sources = append(termPackage.Sources, source)
-          }
-
-          termsRows := QueryTermsForPost(thisPostuid)
-            for termsRows.Next() {
-              var wcuid int
-              var wcpostid int
-              var wcTerm string
-              var wordcount int
-              var wcPosted time.Time
-              var wcLocation string
-              var wcLocationHash int
-              var wcSource string
-              err := termsRows.Scan(&wcuid, &wcpostid, &wcTerm, &wordcount, &wcPosted, &wcLocation, &wcLocationHash, &wcSource)
-              checkErr(err)
-              if _, ok := related[wcTerm]; ok {
-                related[wcTerm] += wordcount
-              } else {
-                related[wcTerm] = wordcount
-              }
-            }
-        }
-      }
+    if _, ok := sourceSerieses[bucket.Source]; !ok {
+      sourceSerieses[bucket.Source] = make([]int, int(interval))
     }
-
-    // TODO: Need to sort the related terms by velocity
-    fromTime = fromTime.Add(duration)
-    fromParam = fromTime.Format("200601021504")
+    sourceSerieses[bucket.Source][bucket.Bucket] = sourceSerieses[bucket.Source][bucket.Bucket] + bucket.Occurrences
   }
 
-  for key, value := range sourceSerieses {
+  for _, key := range sortedSeriesKeys(sourceSerieses) {
     termPackage.SourceTypes = append(termPackage.SourceTypes, SourceType {
       Name: key,
-      Series: value,
+      Series: sourceSerieses[key],
       })
   }
 
-  for _, res := range sortedKeys(related) {
-    // Exclude the term we are finding related terms for
-    if term != res {
-      termPackage.Related = append(termPackage.Related, Related {
-        Term: res,
-        Occurrences: related[res],
-        })
+  termPackage.Forms = resolved.Forms(forms)
+
+  posts, err := store.TermSources(source, location, resolved.Components, fromTime, toTime, clampLimit(sourcesLimit, DefaultSourcesLimit, MaxSourcesLimit))
+  checkErr(err)
+  for _, post := range posts {
+    termSource := Source {
+      Source: post.Source,
+      Location: post.Location,
+      SourceURI: post.SourceURI,
+      Posted: post.Posted,
+      Mined: post.Mined,
     }
+    if resolved.Aliased {
+      termSource.Terms = post.Matched
+    }
+    termPackage.Sources = append(termPackage.Sources, termSource)
   }
 
+  // Counted related terms come back ranked and limited by the store, but
+  // association scores need every candidate before the best can be picked
+  if relatedMinSupport < 1 {
+    relatedMinSupport = defaultMinSupport(relatedScoring)
+  }
+  relatedLimit = clampLimit(relatedLimit, DefaultRelatedLimit, MaxRelatedLimit)
+  // Stopwords are only removed as trends are read, so enough extra related
+  // terms are fetched to make up for any that are stopwords
+  stopwords := CollectStopwords(store, location, source, "")
+  storeLimit := relatedLimit + len(stopwords)
+  if relatedScoring != RelatedByCount {
+    storeLimit = 0
+  }
+  candidates, err := store.RelatedTerms(source, location, resolved.Components, fromTime, toTime, relatedMinSupport, storeLimit)
+  checkErr(err)
+  related := []Related {}
+  for _, candidate := range candidates {
+    if !stringInSlice(candidate.Term, stopwords) {
+      related = append(related, candidate)
+    }
+  }
+  termPosts, totalPosts := 0, 0
+  if relatedScoring != RelatedByCount && len(related) > 0 {
+    termPosts, err = store.CountPosts(source, location, resolved.Components, fromTime, toTime)
+    checkErr(err)
+    totalPosts, err = store.CountPosts(source, location, nil, fromTime, toTime)
+    checkErr(err)
+  }
+  ScoreRelated(related, relatedScoring, termPosts, totalPosts)
+  if len(related) > relatedLimit {
+    related = related[:relatedLimit]
+  }
+  termPackage.RelatedScoring = relatedScoring
+  termPackage.Related = append(termPackage.Related, related...)
+
   // Calculate the velocity
   seriesAverage := float64(totalOccurrences) / float64(interval)
   if seriesAverage != 0 {
@@ -407,5 +362,1004 @@ func TrendsCollection(source string, location string, term string, fromParam str
   fmt.Println(termPackage)
   */
 
-  return termPackage
+  return termPackage, nil
+}
+
+// Length of the baseline window compared against when none is given
+const DefaultBaselineDays = 30
+
+// Defaults for the emerging terms endpoint
+const (
+  DefaultEmergingLimit = 20
+  DefaultEmergingMinimum = 2
+)
+
+// Returns the total occurrences and mentions of each term in buckets,
+// leaving out stopwords and counting aliases under their canonical term,
+// along with each term's forms and the occurrences of all terms.
+func termTotals(buckets TermBuckets, stopwords map[string]bool, aliasMap AliasMap) (occurrences map[string]int, mentions map[string]int, forms map[string]map[string]int, total int) {
+  occurrences = map[string]int {}
+  mentions = map[string]int {}
+  forms = map[string]map[string]int {}
+  for _, bucket := range buckets {
+    total += bucket.Occurrences
+    if strings.ContainsAny(bucket.Term, "<>[]/:;()=\"") || stopwords[bucket.Term] {
+      continue
+    }
+    term := aliasMap.Canonical(bucket.Term)
+    if _, ok := forms[term]; !ok {
+      forms[term] = map[string]int {}
+    }
+    occurrences[term] += bucket.Occurrences
+    mentions[term] += bucket.Mentions
+    forms[term][bucket.Term] += bucket.Occurrences
+  }
+  return
+}
+
+// Returns the totals of every term between from and to, read from the
+// rollups when the window is whole hours.
+func windowBuckets(store Store, source string, location string, from time.Time, to time.Time, ngram NgramRange, lang string) (TermBuckets, error) {
+  return RollupWindowBuckets(store, source, location, from, to, 1, ngram, lang)
+}
+
+// Finds the terms used significantly more between from and to than in the
+// baseline window, by default the DefaultBaselineDays before from. A term's
+// share of all occurrences in each window is compared with a log-likelihood
+// ratio, and terms whose share rose with a ratio of at least minNovelty,
+// and that occur at least minOccurrences times, are returned most novel
+// first. Terms never seen in the baseline have the highest ratios.
+func EmergingTermsCollection(store Store, location string, source string, fromParam string, toParam string, baselineFromParam string, baselineToParam string, limit int, minOccurrences int, minNovelty float64, ngram NgramRange, lang string) (emerging EmergingTerms, collectionErr error) {
+
+  defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            collectionErr, ok = r.(error)
+            if !ok {
+                collectionErr = fmt.Errorf("EmergingTermsCollection: %v", r)
+            }
+        }
+    }()
+
+  if location == "all" {
+    location = ""
+  }
+
+  _, _, fromTime, toTime, err := parseWindow(fromParam, toParam)
+  if err != nil {
+    return nil, err
+  }
+
+  baselineToTime := fromTime
+  if baselineToParam != "" {
+    baselineToTime, err = time.Parse("200601021504", baselineToParam)
+    if err != nil {
+      return nil, validationErrorf("invalid baseline_to date: %v", err)
+    }
+  }
+  baselineFromTime := baselineToTime.Add(-DefaultBaselineDays * 24 * time.Hour)
+  if baselineFromParam != "" {
+    baselineFromTime, err = time.Parse("200601021504", baselineFromParam)
+    if err != nil {
+      return nil, validationErrorf("invalid baseline_from date: %v", err)
+    }
+  }
+  if !baselineFromTime.Before(baselineToTime) {
+    return nil, validationErrorf("baseline_from must be before baseline_to")
+  }
+
+  stopwords := map[string]bool {}
+  for _, word := range CollectStopwords(store, location, source, lang) {
+    stopwords[word] = true
+  }
+  aliases, err := store.Aliases()
+  checkErr(err)
+  aliasMap := aliases.Map()
+
+  buckets, err := windowBuckets(store, source, location, fromTime, toTime, ngram, lang)
+  checkErr(err)
+  occurrences, mentions, forms, total := termTotals(buckets, stopwords, aliasMap)
+
+  baselineBuckets, err := windowBuckets(store, source, location, baselineFromTime, baselineToTime, ngram, lang)
+  checkErr(err)
+  baselineOccurrences, _, _, baselineTotal := termTotals(baselineBuckets, stopwords, aliasMap)
+
+  hours := toTime.Sub(fromTime).Hours()
+  baselineHours := baselineToTime.Sub(baselineFromTime).Hours()
+
+  candidates := EmergingTerms {}
+  for term, count := range occurrences {
+    if count < minOccurrences {
+      continue
+    }
+    baselineCount := baselineOccurrences[term]
+    // Only terms whose share of all occurrences rose
+    if baselineTotal > 0 && float64(count) / float64(total) <= float64(baselineCount) / float64(baselineTotal) {
+      continue
+    }
+    novelty := logLikelihood(float64(count), float64(baselineCount), float64(total), float64(baselineTotal))
+    if novelty < minNovelty {
+      continue
+    }
+
+    emergingTerm := EmergingTerm {
+      Term: term,
+      Ngram: NgramOf(term),
+      Occurrences: count,
+      Mentions: mentions[term],
+      BaselineOccurrences: baselineCount,
+      Rate: float64(count) / hours,
+      BaselineRate: float64(baselineCount) / baselineHours,
+      Novelty: novelty,
+    }
+    if aliasMap.Aliased(term) {
+      emergingTerm.Forms = NewTermForms(forms[term])
+    }
+    candidates = append(candidates, emergingTerm)
+  }
+
+  sort.SliceStable(candidates, func(i, j int) bool {
+    if candidates[i].Novelty != candidates[j].Novelty {
+      return candidates[i].Novelty > candidates[j].Novelty
+    }
+    return candidates[i].Term < candidates[j].Term
+  })
+  if limit > 0 && len(candidates) > limit {
+    candidates = candidates[:limit]
+  }
+
+  components := []string {}
+  for _, candidate := range candidates {
+    components = append(components, aliasMap.Components(candidate.Term)...)
+  }
+  firstSeen, err := store.FirstSeen(source, location, components)
+  checkErr(err)
+  emerging = EmergingTerms {}
+  for _, candidate := range candidates {
+    for _, component := range aliasMap.Components(candidate.Term) {
+      if seen, ok := firstSeen[component]; ok && (candidate.FirstSeen.IsZero() || seen.Before(candidate.FirstSeen)) {
+        candidate.FirstSeen = seen
+      }
+    }
+    candidate.New = !candidate.FirstSeen.Before(fromTime)
+    emerging = append(emerging, candidate)
+  }
+  return
+}
+
+// Defaults for term forecasts
+const (
+  DefaultForecastInterval = 24
+  DefaultForecastHorizon = 6
+  MaxForecastHorizon = 1000
+  DefaultForecastConfidence = 0.95
+)
+
+// Returns the number of intervals of the given width in a day, when a day
+// is a whole number of them and the window covers at least two days, or 0
+// for no season.
+func dailySeason(width time.Duration, interval int) int {
+  if width <= 0 || width >= 24 * time.Hour || (24 * time.Hour) % width != 0 {
+    return 0
+  }
+  season := int(24 * time.Hour / width)
+  if interval < 2 * season {
+    return 0
+  }
+  return season
+}
+
+// Fits the forecaster registered as model ("" for the default) to a
+// term's series of interval counts between from and to, and predicts the
+// next horizon intervals. A season of less than 0 uses a daily season when
+// the window covers one.
+func TermForecastCollection(store Store, source string, location string, term string, fromParam string, toParam string, interval int, horizon int, season int, confidence float64, model string) (forecast TermForecast, collectionErr error) {
+
+  defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            collectionErr, ok = r.(error)
+            if !ok {
+                collectionErr = fmt.Errorf("TermForecastCollection: %v", r)
+            }
+        }
+    }()
+
+  if location == "all" {
+    location = ""
+  }
+  if model == "" {
+    model = DefaultForecastModel
+  }
+  forecaster, err := ForecasterFor(model)
+  if err != nil {
+    return forecast, ValidationError {Message: err.Error()}
+  }
+  interval, err = parseInterval(interval, DefaultForecastInterval)
+  if err != nil {
+    return forecast, err
+  }
+  horizon = clampLimit(horizon, DefaultForecastHorizon, MaxForecastHorizon)
+  if confidence <= 0 || confidence >= 1 {
+    confidence = DefaultForecastConfidence
+  }
+
+  _, _, fromTime, toTime, err := parseWindow(fromParam, toParam)
+  if err != nil {
+    return forecast, err
+  }
+
+  resolved, err := termForms(store, term)
+  checkErr(err)
+
+  buckets, err := RollupWindowSourceBuckets(store, source, location, resolved.Components, fromTime, toTime, interval)
+  checkErr(err)
+
+  series := make([]int, interval)
+  for _, bucket := range buckets {
+    series[bucket.Bucket] += bucket.Occurrences
+  }
+  values := make([]float64, interval)
+  for i, count := range series {
+    values[i] = float64(count)
+  }
+
+  width := toTime.Sub(fromTime) / time.Duration(interval)
+  if season < 0 {
+    season = dailySeason(width, interval)
+  }
+  if season < 2 || interval < 2 * season {
+    season = 0
+  }
+
+  predictions, deviations := forecaster.Forecast(values, season, horizon)
+  multiplier := confidenceMultiplier(confidence)
+
+  forecast = TermForecast {
+    Term: resolved.Term,
+    Model: strings.ToLower(model),
+    Season: season,
+    Confidence: confidence,
+    IntervalSeconds: width.Seconds(),
+    From: fromTime,
+    To: toTime,
+    Series: series,
+    Forecast: []ForecastPoint {},
+  }
+  // Counts can't be negative, so neither can predictions or their bounds
+  for h, prediction := range predictions {
+    forecast.Forecast = append(forecast.Forecast, ForecastPoint {
+      Start: toTime.Add(width * time.Duration(h)),
+      Value: math.Max(prediction, 0),
+      Lower: math.Max(prediction - multiplier * deviations[h], 0),
+      Upper: math.Max(prediction + multiplier * deviations[h], 0),
+    })
+  }
+  last := forecast.Forecast[len(forecast.Forecast) - 1]
+  forecast.Growing = last.Lower > values[len(values) - 1]
+  return
+}
+
+// The most buckets a window can be split into
+const MaxInterval = 1000
+
+// An error in a request's parameters rather than in reading the store, so
+// handlers can answer it with a 400 rather than a 500.
+type ValidationError struct {
+  Message string
+}
+
+func (e ValidationError) Error() string {
+  return e.Message
+}
+
+func validationErrorf(format string, args ...interface{}) error {
+  return ValidationError {Message: fmt.Sprintf(format, args...)}
+}
+
+// Returns the number of buckets to split a window into, fallback when
+// interval isn't set.
+func parseInterval(interval int, fallback int) (int, error) {
+  if interval < 1 {
+    return fallback, nil
+  }
+  if interval > MaxInterval {
+    return interval, validationErrorf("interval must be at most %d", MaxInterval)
+  }
+  return interval, nil
+}
+
+// Parses the from and to parameters of a window, defaulting to the last
+// 24 hours, and returns them with the parameters filled in. A window that
+// doesn't end after it starts is a ValidationError, as it can't be split
+// into buckets.
+func parseWindow(fromParam string, toParam string) (string, string, time.Time, time.Time, error) {
+  t := time.Now()
+  if fromParam == "" {
+    fromParam = t.Add(-24 * time.Hour).Format("200601021504")
+  }
+  if toParam == "" {
+    toParam = t.Format("200601021504")
+  }
+  fromTime, err := time.Parse("200601021504", fromParam)
+  if err != nil {
+    return fromParam, toParam, fromTime, fromTime, validationErrorf("invalid from date: %v", err)
+  }
+  toTime, err := time.Parse("200601021504", toParam)
+  if err != nil {
+    return fromParam, toParam, fromTime, toTime, validationErrorf("invalid to date: %v", err)
+  }
+  if !fromTime.Before(toTime) {
+    return fromParam, toParam, fromTime, toTime, validationErrorf("from must be before to")
+  }
+  return fromParam, toParam, fromTime, toTime, nil
+}
+
+// A term resolved against the aliases, see termForms
+type resolvedTerm struct {
+  // The canonical term the term is counted under
+  Term string
+  // The terms counted together as Term, for the store to match
+  Components []string
+  // Whether Term has aliases, and so has its forms listed
+  Aliased bool
+}
+
+// Resolves term, which may be an alias, to the canonical term it is counted
+// under and the component terms counted with it.
+func termForms(store Store, term string) (resolvedTerm, error) {
+  aliases, err := store.Aliases()
+  if err != nil {
+    return resolvedTerm {}, err
+  }
+  aliasMap := aliases.Map()
+  canonical := aliasMap.Canonical(term)
+  return resolvedTerm {
+    Term: canonical,
+    Components: aliasMap.Components(term),
+    Aliased: aliasMap.Aliased(canonical),
+  }, nil
+}
+
+// Lists the occurrences of each component term counted, or nil when the
+// term has no aliases.
+func (t resolvedTerm) Forms(occurrences map[string]int) TermForms {
+  if !t.Aliased {
+    return nil
+  }
+  return NewTermForms(occurrences)
+}
+
+// Counts the posts each pair of counts shares, keyed by their indexes with
+// the lower first. Counts merge their aliases, so their co-occurrences are
+// those of all their forms. Also returns the index of the count each form
+// is merged into and the forms of each count.
+func coOccurrenceWeights(store Store, source string, location string, counts WordCounts, from time.Time, to time.Time) (weights map[[2]int]int, index map[string]int, forms [][]string) {
+  index = map[string]int {}
+  forms = make([][]string, len(counts))
+  allForms := []string {}
+  for i, count := range counts {
+    index[count.Term] = i
+    forms[i] = []string{count.Term}
+    if len(count.Forms) > 0 {
+      forms[i] = []string {}
+      for _, form := range count.Forms {
+        index[form.Term] = i
+        forms[i] = append(forms[i], form.Term)
+      }
+    }
+    allForms = append(allForms, forms[i]...)
+  }
+
+  pairs, err := store.CoOccurrences(source, location, allForms, from, to)
+  checkErr(err)
+  weights = map[[2]int]int {}
+  for _, pair := range pairs {
+    i, ok := index[pair.Term]
+    j, otherOk := index[pair.Other]
+    if !ok || !otherOk || i == j {
+      continue
+    }
+    if i > j {
+      i, j = j, i
+    }
+    weights[[2]int{i, j}] += pair.Posts
+  }
+  return
+}
+
+// Defaults and limits for topics
+const (
+  DefaultTopicTerms = 50
+  MaxTopicTerms = 200
+  DefaultTopicMinCoOccurrence = 2
+  DefaultTopicMinSize = 2
+  DefaultTopicSourcesLimit = 5
+  MaxTopicSourcesLimit = 100
+  // Recent posts searched for a topic's representative sources
+  TopicSourceCandidates = 1000
+)
+
+// Clusters the top limit trending terms, as ranked by algorithm, into
+// topics: groups of terms used together in at least minCoOccurrence posts,
+// found by Louvain community detection on the graph of their
+// co-occurrences. Topics with fewer than minSize terms are left out, and
+// topics come in order of their highest ranked term.
+func TopicsCollection(store Store, location string, source string, fromParam string, toParam string, interval int, limit int, minCoOccurrence int, minSize int, sourcesLimit int, ngram NgramRange, lang string, algorithm string) (topics Topics, collectionErr error) {
+
+  defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            collectionErr, ok = r.(error)
+            if !ok {
+                collectionErr = fmt.Errorf("TopicsCollection: %v", r)
+            }
+        }
+    }()
+
+  if location == "all" {
+    location = ""
+  }
+  interval, err := parseInterval(interval, 2)
+  if err != nil {
+    return topics, err
+  }
+  limit = clampLimit(limit, DefaultTopicTerms, MaxTopicTerms)
+  if minCoOccurrence < 1 {
+    minCoOccurrence = DefaultTopicMinCoOccurrence
+  }
+  if minSize < 1 {
+    minSize = DefaultTopicMinSize
+  }
+  sourcesLimit = clampLimit(sourcesLimit, DefaultTopicSourcesLimit, MaxTopicSourcesLimit)
+
+  fromParam, toParam, fromTime, toTime, err := parseWindow(fromParam, toParam)
+  if err != nil {
+    return topics, err
+  }
+
+  counts, err := WordCountRootCollection(store, location, source, fromParam, toParam, interval, limit, ngram, nil, lang, algorithm)
+  checkErr(err)
+  if len(counts) > limit {
+    counts = counts[:limit]
+  }
+
+  topics = Topics {}
+  if len(counts) == 0 {
+    return
+  }
+
+  weights, index, forms := coOccurrenceWeights(store, source, location, counts, fromTime, toTime)
+
+  graph := newWeightedGraph(len(counts))
+  for edge, weight := range weights {
+    if weight >= minCoOccurrence {
+      graph.addEdge(edge[0], edge[1], float64(weight))
+    }
+  }
+  community := louvain(graph)
+
+  links := make([]int, len(counts))
+  for edge, weight := range weights {
+    if weight >= minCoOccurrence && community[edge[0]] == community[edge[1]] {
+      links[edge[0]] += weight
+      links[edge[1]] += weight
+    }
+  }
+
+  // Communities are taken in order of their first member, and so of their
+  // highest ranked term, whatever louvain numbers them
+  members := map[int][]int {}
+  order := []int {}
+  for i, c := range community {
+    if _, ok := members[c]; !ok {
+      order = append(order, c)
+    }
+    members[c] = append(members[c], i)
+  }
+  for _, c := range order {
+    if len(members[c]) < minSize {
+      continue
+    }
+    topic := Topic {
+      Id: len(topics) + 1,
+      Terms: []TopicTerm {},
+      Series: make([]int, interval),
+      Sources: []Source {},
+    }
+    topicForms := []string {}
+    for _, i := range members[c] {
+      topic.Terms = append(topic.Terms, TopicTerm {
+        Term: counts[i].Term,
+        Occurrences: counts[i].Occurrences,
+        Score: counts[i].Score,
+        Links: links[i],
+      })
+      topic.Occurrences += counts[i].Occurrences
+      for bucket, count := range counts[i].Series {
+        topic.Series[bucket] += count
+      }
+      topicForms = append(topicForms, forms[i]...)
+    }
+    topic.Velocity = VelocityScorer{}.Score(topic.Series).Value
+    topic.Sources = topicSources(store, source, location, topicForms, fromTime, toTime, counts, index, sourcesLimit)
+    topics = append(topics, topic)
+  }
+  return
+}
+
+// Returns the limit posts using the most of a topic's terms, most recent
+// first among those using as many, each with the trending terms it used.
+func topicSources(store Store, source string, location string, forms []string, from time.Time, to time.Time, counts WordCounts, index map[string]int, limit int) []Source {
+  posts, err := store.TermSources(source, location, forms, from, to, TopicSourceCandidates)
+  checkErr(err)
+
+  used := make([][]string, len(posts))
+  for p, post := range posts {
+    for _, form := range post.Matched {
+      if i, ok := index[form]; ok && !stringInSlice(counts[i].Term, used[p]) {
+        used[p] = append(used[p], counts[i].Term)
+      }
+    }
+  }
+
+  order := make([]int, len(posts))
+  for p := range order {
+    order[p] = p
+  }
+  sort.SliceStable(order, func(a, b int) bool {
+    if len(used[order[a]]) != len(used[order[b]]) {
+      return len(used[order[a]]) > len(used[order[b]])
+    }
+    return posts[order[a]].Posted.After(posts[order[b]].Posted)
+  })
+
+  sources := []Source {}
+  for _, p := range order {
+    if len(sources) == limit {
+      break
+    }
+    sources = append(sources, Source {
+      Source: posts[p].Source,
+      Location: posts[p].Location,
+      SourceURI: posts[p].SourceURI,
+      Posted: posts[p].Posted,
+      Mined: posts[p].Mined,
+      Terms: used[p],
+    })
+  }
+  return sources
+}
+
+// Defaults and limits for co-occurrence networks
+const (
+  DefaultNetworkNodes = 100
+  MaxNetworkNodes = 500
+  DefaultNetworkMinWeight = 2
+)
+
+// Builds the co-occurrence network of the top limit trending terms, as
+// ranked by algorithm. Terms used fewer than minOccurrences times and
+// edges shared by fewer than minWeight posts are pruned, as are terms left
+// without edges unless isolated is set.
+func NetworkCollection(store Store, location string, source string, fromParam string, toParam string, interval int, limit int, minOccurrences int, minWeight int, isolated bool, ngram NgramRange, lang string, algorithm string) (network Network, collectionErr error) {
+
+  defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            collectionErr, ok = r.(error)
+            if !ok {
+                collectionErr = fmt.Errorf("NetworkCollection: %v", r)
+            }
+        }
+    }()
+
+  network = Network {
+    Location: location,
+    Nodes: []NetworkNode {},
+    Edges: []NetworkEdge {},
+  }
+  if location == "all" {
+    location = ""
+  }
+  interval, err := parseInterval(interval, 2)
+  if err != nil {
+    return network, err
+  }
+  limit = clampLimit(limit, DefaultNetworkNodes, MaxNetworkNodes)
+  if minWeight < 1 {
+    minWeight = DefaultNetworkMinWeight
+  }
+
+  fromParam, toParam, fromTime, toTime, err := parseWindow(fromParam, toParam)
+  if err != nil {
+    return network, err
+  }
+  network.From = fromTime
+  network.To = toTime
+
+  // Every term is ranked and those under minOccurrences dropped before the
+  // limit is taken, so they don't use up places other terms would fill
+  ranked, err := WordCountRootCollection(store, location, source, fromParam, toParam, interval, math.MaxInt32, ngram, nil, lang, algorithm)
+  checkErr(err)
+  counts := WordCounts {}
+  for _, count := range ranked {
+    if len(counts) == limit {
+      break
+    }
+    if count.Occurrences >= minOccurrences {
+      counts = append(counts, count)
+    }
+  }
+
+  weights, _, _ := coOccurrenceWeights(store, source, location, counts, fromTime, toTime)
+  edges := [][2]int {}
+  linked := make([]bool, len(counts))
+  for edge, weight := range weights {
+    if weight >= minWeight {
+      edges = append(edges, edge)
+      linked[edge[0]] = true
+      linked[edge[1]] = true
+    }
+  }
+  sort.Slice(edges, func(i, j int) bool {
+    if weights[edges[i]] != weights[edges[j]] {
+      return weights[edges[i]] > weights[edges[j]]
+    }
+    if edges[i][0] != edges[j][0] {
+      return edges[i][0] < edges[j][0]
+    }
+    return edges[i][1] < edges[j][1]
+  })
+
+  // Nodes are numbered in rank order, after pruning
+  ids := make([]int, len(counts))
+  for i, count := range counts {
+    if !linked[i] && !isolated {
+      continue
+    }
+    ids[i] = len(network.Nodes)
+    network.Nodes = append(network.Nodes, NetworkNode {
+      Id: ids[i],
+      Term: count.Term,
+      Occurrences: count.Occurrences,
+      Velocity: count.Velocity,
+      Score: count.Score,
+    })
+  }
+  for _, edge := range edges {
+    network.Edges = append(network.Edges, NetworkEdge {
+      Source: ids[edge[0]],
+      Target: ids[edge[1]],
+      Weight: weights[edge],
+    })
+  }
+  return
+}
+
+// Defaults and limits for comparing a term across locations
+const (
+  DefaultCompareInterval = 24
+  MaxCompareLocations = 20
+)
+
+// Returns the velocity of a series: its last value over its mean, or 0 for
+// an empty series.
+func seriesVelocity(series []float64) float64 {
+  total := 0.0
+  for _, value := range series {
+    total += value
+  }
+  if total == 0 {
+    return 0
+  }
+  return series[len(series) - 1] / (total / float64(len(series)))
+}
+
+// Buckets a term's occurrences in each of locations over the same window,
+// alongside each location's post volume, and ranks the locations by how
+// fast the term is rising once that volume is allowed for.
+func TermComparisonCollection(store Store, source string, locations []string, term string, fromParam string, toParam string, interval int) (comparison TermComparison, collectionErr error) {
+
+  defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            collectionErr, ok = r.(error)
+            if !ok {
+                collectionErr = fmt.Errorf("TermComparisonCollection: %v", r)
+            }
+        }
+    }()
+
+  if len(locations) == 0 {
+    return comparison, validationErrorf("locations must name at least one location")
+  }
+  if len(locations) > MaxCompareLocations {
+    return comparison, validationErrorf("at most %d locations can be compared", MaxCompareLocations)
+  }
+  interval, err := parseInterval(interval, DefaultCompareInterval)
+  if err != nil {
+    return comparison, err
+  }
+  _, _, fromTime, toTime, err := parseWindow(fromParam, toParam)
+  if err != nil {
+    return comparison, err
+  }
+
+  resolved, err := termForms(store, term)
+  checkErr(err)
+
+  comparison = TermComparison {
+    Term: resolved.Term,
+    From: fromTime,
+    To: toTime,
+    IntervalSeconds: toTime.Sub(fromTime).Seconds() / float64(interval),
+    Locations: []LocationTrend {},
+    Ranking: []string {},
+  }
+
+  forms := map[string]int {}
+  for _, location := range locations {
+    storeLocation := location
+    if location == "all" {
+      storeLocation = ""
+    }
+
+    buckets, err := RollupWindowSourceBuckets(store, source, storeLocation, resolved.Components, fromTime, toTime, interval)
+    checkErr(err)
+    posts, err := store.PostBuckets(source, storeLocation, fromTime, toTime, interval)
+    checkErr(err)
+
+    trend := LocationTrend {
+      Location: location,
+      Series: make([]int, interval),
+      Posts: posts,
+      Normalised: make([]float64, interval),
+    }
+    for _, bucket := range buckets {
+      trend.Series[bucket.Bucket] += bucket.Occurrences
+      trend.Occurrences += bucket.Occurrences
+      forms[bucket.Term] += bucket.Occurrences
+    }
+    raw := make([]float64, interval)
+    for i, count := range trend.Series {
+      raw[i] = float64(count)
+      if posts[i] > 0 {
+        trend.Normalised[i] = 1000 * float64(count) / float64(posts[i])
+      }
+    }
+    trend.Velocity = seriesVelocity(raw)
+    trend.NormalisedVelocity = seriesVelocity(trend.Normalised)
+    comparison.Locations = append(comparison.Locations, trend)
+  }
+
+  sort.SliceStable(comparison.Locations, func(i, j int) bool {
+    a, b := comparison.Locations[i], comparison.Locations[j]
+    if a.NormalisedVelocity != b.NormalisedVelocity {
+      return a.NormalisedVelocity > b.NormalisedVelocity
+    }
+    return a.Occurrences > b.Occurrences
+  })
+  for i := range comparison.Locations {
+    comparison.Locations[i].Rank = i + 1
+    comparison.Ranking = append(comparison.Ranking, comparison.Locations[i].Location)
+  }
+
+  comparison.Forms = resolved.Forms(forms)
+  return
+}
+
+// Default number of buckets in diffusion curves
+const DefaultDiffusionInterval = 24
+
+// Tracks how a term spread across the locations in BuildLocationsList:
+// when each first used it, in what order, the lags between them, and each
+// location's cumulative occurrences over the window. The window defaults
+// to the term's first use anywhere until now.
+func TermDiffusionCollection(store Store, source string, term string, fromParam string, toParam string, interval int) (diffusion TermDiffusion, collectionErr error) {
+
+  defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            collectionErr, ok = r.(error)
+            if !ok {
+                collectionErr = fmt.Errorf("TermDiffusionCollection: %v", r)
+            }
+        }
+    }()
+
+  interval, err := parseInterval(interval, DefaultDiffusionInterval)
+  if err != nil {
+    return diffusion, err
+  }
+
+  resolved, err := termForms(store, term)
+  checkErr(err)
+
+  locations, err := BuildLocationsList(store)
+  checkErr(err)
+
+  // The earliest use of any of the term's forms in each location
+  adoptions := []LocationAdoption {}
+  for _, location := range locations {
+    if location.Name == "all" {
+      continue
+    }
+    firstSeen, err := store.FirstSeen(source, location.Name, resolved.Components)
+    checkErr(err)
+    adoption := LocationAdoption {Location: location.Name, GeoCoord: location.GeoCoord}
+    for _, seen := range firstSeen {
+      if adoption.FirstSeen == nil || seen.Before(*adoption.FirstSeen) {
+        first := seen
+        adoption.FirstSeen = &first
+      }
+    }
+    adoptions = append(adoptions, adoption)
+  }
+  sort.SliceStable(adoptions, func(i, j int) bool {
+    a, b := adoptions[i].FirstSeen, adoptions[j].FirstSeen
+    if a == nil || b == nil {
+      return b == nil && a != nil
+    }
+    if !a.Equal(*b) {
+      return a.Before(*b)
+    }
+    return adoptions[i].Location < adoptions[j].Location
+  })
+
+  diffusion = TermDiffusion {
+    Term: resolved.Term,
+    Lags: []AdoptionLag {},
+  }
+  for i := range adoptions {
+    adoption := &adoptions[i]
+    if adoption.FirstSeen == nil {
+      continue
+    }
+    adoption.Order = i + 1
+    if i == 0 {
+      diffusion.Origin = adoption.Location
+      continue
+    }
+    adoption.Previous = adoptions[i - 1].Location
+    adoption.LagSeconds = adoption.FirstSeen.Sub(*adoptions[0].FirstSeen).Seconds()
+    adoption.PreviousLagSeconds = adoption.FirstSeen.Sub(*adoptions[i - 1].FirstSeen).Seconds()
+    for _, earlier := range adoptions[:i] {
+      diffusion.Lags = append(diffusion.Lags, AdoptionLag {
+        From: earlier.Location,
+        To: adoption.Location,
+        LagSeconds: adoption.FirstSeen.Sub(*earlier.FirstSeen).Seconds(),
+      })
+    }
+  }
+
+  // The default window runs from the hour of the first use to now
+  if fromParam == "" && len(adoptions) > 0 && adoptions[0].FirstSeen != nil {
+    fromParam = adoptions[0].FirstSeen.UTC().Truncate(time.Hour).Format("200601021504")
+  }
+  _, _, fromTime, toTime, err := parseWindow(fromParam, toParam)
+  if err != nil {
+    return diffusion, err
+  }
+
+  diffusion.From = fromTime
+  diffusion.To = toTime
+  diffusion.IntervalSeconds = toTime.Sub(fromTime).Seconds() / float64(interval)
+
+  forms := map[string]int {}
+  for i := range adoptions {
+    buckets, err := RollupWindowSourceBuckets(store, source, adoptions[i].Location, resolved.Components, fromTime, toTime, interval)
+    checkErr(err)
+
+    series := make([]int, interval)
+    for _, bucket := range buckets {
+      series[bucket.Bucket] += bucket.Occurrences
+      forms[bucket.Term] += bucket.Occurrences
+    }
+    adoptions[i].Cumulative = make([]int, interval)
+    for b, count := range series {
+      adoptions[i].Occurrences += count
+      adoptions[i].Cumulative[b] = adoptions[i].Occurrences
+    }
+  }
+  diffusion.Locations = adoptions
+
+  diffusion.Forms = resolved.Forms(forms)
+  return
+}
+
+// Limits for charting several terms together
+const (
+  DefaultChartInterval = 24
+  MaxChartTerms = 10
+)
+
+// Builds the series of each of terms over the same buckets, along with
+// each term's share of the occurrences of all of them per bucket. Only the
+// buckets are read, not the related terms and sources of TrendsCollection.
+// Terms counted under the same canonical term are charted once.
+func TermChartCollection(store Store, source string, location string, terms []string, fromParam string, toParam string, interval int) (chart TermChart, collectionErr error) {
+
+  defer func() {
+        if r := recover(); r != nil {
+            var ok bool
+            collectionErr, ok = r.(error)
+            if !ok {
+                collectionErr = fmt.Errorf("TermChartCollection: %v", r)
+            }
+        }
+    }()
+
+  if len(terms) == 0 {
+    return chart, validationErrorf("terms must name at least one term")
+  }
+  if len(terms) > MaxChartTerms {
+    return chart, validationErrorf("at most %d terms can be charted together", MaxChartTerms)
+  }
+  interval, err := parseInterval(interval, DefaultChartInterval)
+  if err != nil {
+    return chart, err
+  }
+  _, _, fromTime, toTime, err := parseWindow(fromParam, toParam)
+  if err != nil {
+    return chart, err
+  }
+
+  width := toTime.Sub(fromTime) / time.Duration(interval)
+  chart = TermChart {
+    Location: location,
+    From: fromTime,
+    To: toTime,
+    IntervalSeconds: width.Seconds(),
+    Buckets: make([]time.Time, interval),
+    Totals: make([]int, interval),
+    Terms: []ChartTerm {},
+  }
+  for i := range chart.Buckets {
+    chart.Buckets[i] = fromTime.Add(width * time.Duration(i))
+  }
+
+  if location == "all" {
+    location = ""
+  }
+  charted := map[string]bool {}
+  for _, term := range terms {
+    resolved, err := termForms(store, term)
+    checkErr(err)
+    if charted[resolved.Term] {
+      continue
+    }
+    charted[resolved.Term] = true
+
+    buckets, err := RollupWindowSourceBuckets(store, source, location, resolved.Components, fromTime, toTime, interval)
+    checkErr(err)
+    chartTerm := ChartTerm {
+      Term: resolved.Term,
+      Series: make([]int, interval),
+    }
+    forms := map[string]int {}
+    for _, bucket := range buckets {
+      chartTerm.Series[bucket.Bucket] += bucket.Occurrences
+      chartTerm.Occurrences += bucket.Occurrences
+      forms[bucket.Term] += bucket.Occurrences
+      chart.Totals[bucket.Bucket] += bucket.Occurrences
+    }
+    chartTerm.Forms = resolved.Forms(forms)
+    // The same velocity as TrendsCollection gives the term
+    seriesAverage := float64(chartTerm.Occurrences) / float64(interval)
+    if seriesAverage != 0 {
+      chartTerm.Velocity = float64(chartTerm.Series[interval - 1]) / seriesAverage
+    }
+    chart.Terms = append(chart.Terms, chartTerm)
+  }
+
+  for t := range chart.Terms {
+    chart.Terms[t].Share = make([]float64, interval)
+    for i, count := range chart.Terms[t].Series {
+      if chart.Totals[i] > 0 {
+        chart.Terms[t].Share[i] = float64(count) / float64(chart.Totals[i])
+      }
+    }
+  }
+  return
 }
\ No newline at end of file
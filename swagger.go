@@ -19,5 +19,5 @@ func Swagger(w http.ResponseWriter, r *http.Request) {
   w.Header().Add("Access-Control-Allow-Origin", "*")
   w.Header().Add("Access-Control-Allow-Methods", "GET")
   w.Header().Add("Access-Control-Allow-Headers", "Content-Type, api_key, Authorization")
-  fmt.Fprintf(w, s)
+  fmt.Fprint(w, s)
 }
\ No newline at end of file
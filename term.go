@@ -0,0 +1,21 @@
+package main
+
+import (
+    "time"
+)
+
+type Term struct {
+  Uid int `json:"id"`
+  PostId int `json:"post_id"`
+  Term string `json:"term"`
+  WordCount int `json:"wordcount"`
+  // Number of words, more than one for a phrase
+  Ngram int `json:"ngram"`
+  Posted time.Time `json:"posted"`
+  Location string `json:"location"`
+  Source string `json:"source"`
+  // Language of the post the term is from
+  Lang string `json:"lang"`
+}
+
+type Terms []Term
@@ -0,0 +1,14 @@
+package main
+
+// The aggregated use of a term within one interval of a time window.
+// Occurrences sums the word counts, Mentions counts the posts. Source is
+// only set when the aggregation is split by source.
+type TermBucket struct {
+  Term string `json:"term"`
+  Source string `json:"source,omitempty"`
+  Bucket int `json:"bucket"`
+  Occurrences int `json:"occurrences"`
+  Mentions int `json:"mentions"`
+}
+
+type TermBuckets []TermBucket
@@ -0,0 +1,32 @@
+package main
+
+import (
+  "time"
+)
+
+// A term plotted on a chart alongside others, with only the series of its
+// TermPackage. Share is the term's part of the occurrences of all the
+// chart's terms in each bucket, from 0 to 1.
+type ChartTerm struct {
+  Term string `json:"term"`
+  Occurrences int `json:"occurrences"`
+  Velocity float64 `json:"velocity"`
+  Series []int `json:"series"`
+  // The terms counted under Term when it has aliases
+  Forms TermForms `json:"forms,omitempty"`
+  Share []float64 `json:"share"`
+}
+
+// Several terms' trends over the same buckets, for plotting together.
+// Buckets holds the start of each bucket and Totals the occurrences of all
+// the terms in it.
+type TermChart struct {
+  Location string `json:"location"`
+  From time.Time `json:"from"`
+  To time.Time `json:"to"`
+  // Length of each bucket, in seconds
+  IntervalSeconds float64 `json:"interval_seconds"`
+  Buckets []time.Time `json:"buckets"`
+  Totals []int `json:"totals"`
+  Terms []ChartTerm `json:"terms"`
+}
\ No newline at end of file
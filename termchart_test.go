@@ -0,0 +1,42 @@
+package main
+
+import (
+  "reflect"
+  "testing"
+  "time"
+)
+
+func TestTermChartCollection(t *testing.T) {
+  store := aliasTestStore(t)
+  from, to := testParam(testNow.Add(-2 * time.Hour)), testParam(testNow)
+
+  // An alias and its canonical term are charted once
+  chart, err := TermChartCollection(store, "", "nairobi", []string {"#ai", "robot", "ai"}, from, to, 2)
+  if err != nil {
+    t.Fatalf("TermChartCollection: %v", err)
+  }
+  if len(chart.Terms) != 2 || chart.Terms[0].Term != "ai" || chart.Terms[1].Term != "robot" {
+    t.Fatalf("chart terms = %+v, want ai and robot", chart.Terms)
+  }
+  if !reflect.DeepEqual(chart.Totals, []int {3, 5}) {
+    t.Errorf("totals = %v, want [3 5]", chart.Totals)
+  }
+
+  // Each term has the series and velocity of its trends
+  for _, chartTerm := range chart.Terms {
+    termPackage, err := TrendsCollection(store, "", "nairobi", chartTerm.Term, from, to, 2, 1.0, 0.0, 0, 0, "", 0)
+    if err != nil {
+      t.Fatalf("TrendsCollection: %v", err)
+    }
+    if !reflect.DeepEqual(chartTerm.Series, termPackage.Series) || chartTerm.Velocity != termPackage.Velocity || !reflect.DeepEqual(chartTerm.Forms, termPackage.Forms) {
+      t.Errorf("chart term = %+v, want the series, velocity and forms of %+v", chartTerm, termPackage)
+    }
+  }
+  if !reflect.DeepEqual(chart.Terms[1].Share, []float64 {2.0 / 3.0, 0}) {
+    t.Errorf("robot share = %v, want [2/3 0]", chart.Terms[1].Share)
+  }
+
+  if _, err := TermChartCollection(store, "", "nairobi", nil, from, to, 2); err == nil {
+    t.Errorf("TermChartCollection accepted no terms")
+  }
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+  "time"
+)
+
+// A term's trend in one location of a comparison. Normalised is the
+// term's occurrences per thousand posts in each bucket, so locations with
+// more posts don't swamp the others, and NormalisedVelocity is the
+// velocity of that series. Rank 1 is where the term is rising fastest.
+type LocationTrend struct {
+  Location string `json:"location"`
+  Rank int `json:"rank"`
+  Occurrences int `json:"occurrences"`
+  Velocity float64 `json:"velocity"`
+  NormalisedVelocity float64 `json:"normalised_velocity"`
+  Series []int `json:"series"`
+  Posts []int `json:"posts"`
+  Normalised []float64 `json:"normalised"`
+}
+
+// A term's trends in several locations over the same buckets, in order of
+// rank
+type TermComparison struct {
+  Term string `json:"term"`
+  From time.Time `json:"from"`
+  To time.Time `json:"to"`
+  // Length of each bucket, in seconds
+  IntervalSeconds float64 `json:"interval_seconds"`
+  Locations []LocationTrend `json:"locations"`
+  // The locations, where the term is rising fastest first
+  Ranking []string `json:"ranking"`
+  // The terms counted under Term when it has aliases
+  Forms TermForms `json:"forms,omitempty"`
+}
\ No newline at end of file
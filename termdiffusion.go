@@ -0,0 +1,45 @@
+package main
+
+import (
+  "time"
+)
+
+// When and how a term was taken up in one location. Locations that never
+// used the term have no FirstSeen and an Order of 0, otherwise Order 1 is
+// where the term surfaced first. Lags are in seconds, from the first
+// location and from the location that took the term up just before.
+// Cumulative counts the term's occurrences up to the end of each bucket of
+// the window.
+type LocationAdoption struct {
+  Location string `json:"location"`
+  GeoCoord Point `json:"geo_coord"`
+  Order int `json:"order"`
+  FirstSeen *time.Time `json:"first_seen"`
+  Previous string `json:"previous,omitempty"`
+  LagSeconds float64 `json:"lag_seconds"`
+  PreviousLagSeconds float64 `json:"previous_lag_seconds"`
+  Occurrences int `json:"occurrences"`
+  Cumulative []int `json:"cumulative"`
+}
+
+// The lag, in seconds, between two locations taking up a term, From
+// before To
+type AdoptionLag struct {
+  From string `json:"from"`
+  To string `json:"to"`
+  LagSeconds float64 `json:"lag_seconds"`
+}
+
+// How a term spread between locations, in order of adoption
+type TermDiffusion struct {
+  Term string `json:"term"`
+  Origin string `json:"origin"`
+  From time.Time `json:"from"`
+  To time.Time `json:"to"`
+  // Length of each bucket of the cumulative curves, in seconds
+  IntervalSeconds float64 `json:"interval_seconds"`
+  Locations []LocationAdoption `json:"locations"`
+  Lags []AdoptionLag `json:"lags"`
+  // The terms counted under Term when it has aliases
+  Forms TermForms `json:"forms,omitempty"`
+}
\ No newline at end of file
@@ -0,0 +1,30 @@
+package main
+
+import (
+  "time"
+)
+
+// A predicted count for one future interval, starting at Start, with the
+// bounds expected to hold it at the forecast's confidence.
+type ForecastPoint struct {
+  Start time.Time `json:"start"`
+  Value float64 `json:"value"`
+  Lower float64 `json:"lower"`
+  Upper float64 `json:"upper"`
+}
+
+// The forecast of a term's series. Growing is set when even the lower
+// bound of the last prediction is above the last observed interval.
+type TermForecast struct {
+  Term string `json:"term"`
+  Model string `json:"model"`
+  Season int `json:"season"`
+  Confidence float64 `json:"confidence"`
+  // Length of each interval, in seconds
+  IntervalSeconds float64 `json:"interval_seconds"`
+  From time.Time `json:"from"`
+  To time.Time `json:"to"`
+  Series []int `json:"series"`
+  Forecast []ForecastPoint `json:"forecast"`
+  Growing bool `json:"growing"`
+}
\ No newline at end of file
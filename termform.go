@@ -0,0 +1,19 @@
+package main
+
+// A surface form merged into a stemmed or canonical term, with its own
+// count.
+type TermForm struct {
+  Term string `json:"term"`
+  Occurrences int `json:"occurrences"`
+}
+
+type TermForms []TermForm
+
+// Lists the occurrences of each form, the most used first.
+func NewTermForms(occurrences map[string]int) TermForms {
+  forms := TermForms {}
+  for _, form := range sortedKeys(occurrences) {
+    forms = append(forms, TermForm{Term: form, Occurrences: occurrences[form]})
+  }
+  return forms
+}
@@ -5,6 +5,10 @@ type TermPackage struct {
   Velocity float64 `json:"velocity"`
   Series []int `json:"series"`
   Related []Related `json:"related"`
+  // How Related was ranked, see ScoreRelated
+  RelatedScoring string `json:"related_scoring"`
   SourceTypes []SourceType `json:"source_types"`
   Sources []Source `json:"sources"`
+  // The terms counted under Term when it has aliases
+  Forms TermForms `json:"forms,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,286 @@
+package main
+
+import (
+  "os"
+  "strconv"
+  "strings"
+  "unicode"
+)
+
+// How the tokenizer treats #hashtags and @mentions
+type TagMode string
+
+const (
+  // Keep the token with its # or @
+  TagKeep TagMode = "keep"
+  // Keep the token without its # or @, so #kenya counts as kenya
+  TagStrip TagMode = "strip"
+  // Remove the token
+  TagDrop TagMode = "drop"
+)
+
+// The steps the tokenizer applies to raw post text. Read from the
+// environment by TokenizerConfigFromEnv.
+type TokenizerConfig struct {
+  Lowercase bool
+  StripPunctuation bool
+  RemoveURLs bool
+  Hashtags TagMode
+  Mentions TagMode
+  Stopwords []string
+  // Longest phrase counted alongside single words, 1 for words only
+  MaxNgram int
+  // Remove the built-in stopwords of the post's language
+  LanguageStopwords bool
+}
+
+// A TokenFilter rewrites a token, returning "" to drop it.
+type TokenFilter func(token string) string
+
+// A Tokenizer splits text on whitespace and passes each token through its
+// filters in order. A token dropped by one filter is not seen by the rest.
+// Phrases of up to MaxNgram consecutive tokens are counted too.
+type Tokenizer struct {
+  Filters []TokenFilter
+  MaxNgram int
+  LanguageStopwords bool
+  // The words the filters remove as stopwords, lowercased
+  Stopwords map[string]bool
+}
+
+func DefaultTokenizerConfig() TokenizerConfig {
+  return TokenizerConfig{
+    Lowercase: true,
+    StripPunctuation: true,
+    RemoveURLs: true,
+    Hashtags: TagStrip,
+    Mentions: TagDrop,
+    MaxNgram: MaxNgram,
+    LanguageStopwords: true,
+  }
+}
+
+// Returns the default tokenizer config overridden by TOKENIZER_LOWERCASE,
+// TOKENIZER_PUNCTUATION and TOKENIZER_URLS (true or false),
+// TOKENIZER_HASHTAGS and TOKENIZER_MENTIONS (keep, strip or drop),
+// TOKENIZER_STOPWORDS (a comma separated list removed from every post),
+// TOKENIZER_NGRAMS (the longest phrase counted, 1 to 3) and
+// TOKENIZER_LANGUAGE_STOPWORDS (true or false).
+func TokenizerConfigFromEnv() TokenizerConfig {
+  config := DefaultTokenizerConfig()
+  config.Lowercase = envBool("TOKENIZER_LOWERCASE", config.Lowercase)
+  config.StripPunctuation = envBool("TOKENIZER_PUNCTUATION", config.StripPunctuation)
+  config.RemoveURLs = envBool("TOKENIZER_URLS", config.RemoveURLs)
+  config.LanguageStopwords = envBool("TOKENIZER_LANGUAGE_STOPWORDS", config.LanguageStopwords)
+  config.Hashtags = envTagMode("TOKENIZER_HASHTAGS", config.Hashtags)
+  config.Mentions = envTagMode("TOKENIZER_MENTIONS", config.Mentions)
+  config.Stopwords = SplitStopwords(os.Getenv("TOKENIZER_STOPWORDS"))
+  if n, err := strconv.Atoi(os.Getenv("TOKENIZER_NGRAMS")); err == nil && n >= 1 && n <= MaxNgram {
+    config.MaxNgram = n
+  }
+  return config
+}
+
+// Returns a copy of the config that also removes stopwords.
+func (c TokenizerConfig) WithStopwords(stopwords ...string) TokenizerConfig {
+  c.Stopwords = append(append([]string {}, c.Stopwords...), stopwords...)
+  return c
+}
+
+// Builds the filter pipeline for a config. URLs are removed before
+// punctuation is stripped so they are recognised whole, and stopwords are
+// matched last, against the lowercased token.
+func NewTokenizer(config TokenizerConfig) Tokenizer {
+  filters := []TokenFilter {}
+  if config.RemoveURLs {
+    filters = append(filters, removeURL)
+  }
+  if config.StripPunctuation {
+    filters = append(filters, stripPunctuation)
+  }
+  filters = append(filters, tagFilter('#', config.Hashtags), tagFilter('@', config.Mentions))
+  if config.Lowercase {
+    filters = append(filters, strings.ToLower)
+  }
+  if len(config.Stopwords) > 0 {
+    filters = append(filters, stopwordFilter(config.Stopwords))
+  }
+  return Tokenizer{Filters: filters, MaxNgram: config.MaxNgram, LanguageStopwords: config.LanguageStopwords, Stopwords: stopwordSet(nil, config.Stopwords)}
+}
+
+// Returns a copy of the tokenizer that also removes the built-in stopwords
+// of language, if it has any and language stopwords are enabled.
+func (t Tokenizer) ForLanguage(language string) Tokenizer {
+  stopwords, ok := LanguageStopwords[language]
+  if !t.LanguageStopwords || !ok {
+    return t
+  }
+  t.Filters = append(append([]TokenFilter {}, t.Filters...), stopwordFilter(stopwords))
+  t.Stopwords = stopwordSet(t.Stopwords, stopwords)
+  return t
+}
+
+// Removes the terms sent by a miner that the tokenizer would not have
+// counted from text: each term with a stopword among its words, as text
+// never forms a phrase across a removed word. Returns a new map.
+func (t Tokenizer) FilterTerms(terms map[string]int) map[string]int {
+  filtered := map[string]int {}
+  for term, count := range terms {
+    stopword := false
+    for _, word := range strings.Fields(term) {
+      if t.Stopwords[strings.ToLower(word)] {
+        stopword = true
+        break
+      }
+    }
+    if !stopword {
+      filtered[term] = count
+    }
+  }
+  return filtered
+}
+
+// Returns the tokens of text that survive the filters, in order.
+func (t Tokenizer) Tokens(text string) (tokens []string) {
+  tokens = []string {}
+  for _, token := range t.filtered(text) {
+    if token != "" {
+      tokens = append(tokens, token)
+    }
+  }
+  return
+}
+
+// Counts how many times each token, and each phrase of up to MaxNgram
+// tokens, appears in text.
+func (t Tokenizer) Count(text string) map[string]int {
+  counts := make(map[string]int)
+  filtered := t.filtered(text)
+  for n := 1; n <= t.MaxNgram || n == 1; n++ {
+    for _, ngram := range Ngrams(filtered, n) {
+      counts[ngram]++
+    }
+  }
+  return counts
+}
+
+// Returns the filtered tokens of text in place, with "" where a token was
+// dropped and after each token that ends a sentence, so that phrases are
+// not formed across them.
+func (t Tokenizer) filtered(text string) (tokens []string) {
+  tokens = []string {}
+  for _, field := range strings.Fields(text) {
+    token := field
+    for _, filter := range t.Filters {
+      token = filter(token)
+      if token == "" {
+        break
+      }
+    }
+    tokens = append(tokens, token)
+    if strings.ContainsAny(field[len(field) - 1:], ".!?;:,") {
+      tokens = append(tokens, "")
+    }
+  }
+  return
+}
+
+// Counts the words of s with the default tokenizer.
+func CountWords(s string) map[string]int {
+  return NewTokenizer(DefaultTokenizerConfig()).Count(s)
+}
+
+// Splits a comma separated stopword list, as entered for a miner, into
+// lowercase words.
+func SplitStopwords(list string) (stopwords []string) {
+  stopwords = []string {}
+  for _, word := range strings.Split(list, ",") {
+    word = strings.ToLower(strings.TrimSpace(word))
+    if word != "" {
+      stopwords = append(stopwords, word)
+    }
+  }
+  return
+}
+
+func removeURL(token string) string {
+  lower := strings.ToLower(token)
+  if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "www.") || strings.Contains(lower, "://") {
+    return ""
+  }
+  return token
+}
+
+func isPunctuation(r rune) bool {
+  return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// Trims punctuation from both ends of a token, keeping a leading # or @ so
+// that hashtags and mentions can still be told apart.
+func stripPunctuation(token string) string {
+  token = strings.TrimRightFunc(token, isPunctuation)
+  token = strings.TrimLeftFunc(token, func(r rune) bool {
+    return isPunctuation(r) && r != '#' && r != '@'
+  })
+  if len(token) > 0 && (token[0] == '#' || token[0] == '@') {
+    rest := strings.TrimLeftFunc(token[1:], isPunctuation)
+    if rest == "" {
+      return ""
+    }
+    return token[:1] + rest
+  }
+  return token
+}
+
+func tagFilter(marker byte, mode TagMode) TokenFilter {
+  return func(token string) string {
+    if len(token) < 2 || token[0] != marker {
+      return token
+    }
+    switch mode {
+    case TagStrip:
+      return token[1:]
+    case TagDrop:
+      return ""
+    }
+    return token
+  }
+}
+
+func stopwordFilter(stopwords []string) TokenFilter {
+  words := stopwordSet(nil, stopwords)
+  return func(token string) string {
+    if words[strings.ToLower(token)] {
+      return ""
+    }
+    return token
+  }
+}
+
+// Returns a copy of set with stopwords added, lowercased.
+func stopwordSet(set map[string]bool, stopwords []string) map[string]bool {
+  words := map[string]bool {}
+  for word := range set {
+    words[word] = true
+  }
+  for _, word := range stopwords {
+    words[strings.ToLower(word)] = true
+  }
+  return words
+}
+
+func envBool(name string, fallback bool) bool {
+  value, err := strconv.ParseBool(os.Getenv(name))
+  if err != nil {
+    return fallback
+  }
+  return value
+}
+
+func envTagMode(name string, fallback TagMode) TagMode {
+  switch mode := TagMode(strings.ToLower(os.Getenv(name))); mode {
+  case TagKeep, TagStrip, TagDrop:
+    return mode
+  }
+  return fallback
+}
\ No newline at end of file
@@ -0,0 +1,24 @@
+package main
+
+// A trending term as a member of a topic
+type TopicTerm struct {
+  Term string `json:"term"`
+  Occurrences int `json:"occurrences"`
+  Score float64 `json:"score"`
+  // Posts the term shares with the other terms of its topic
+  Links int `json:"links"`
+}
+
+// Trending terms used together in the same posts. Series sums the members'
+// series, and Sources are the posts using the most of the members, each
+// with the members it used.
+type Topic struct {
+  Id int `json:"id"`
+  Terms []TopicTerm `json:"terms"`
+  Occurrences int `json:"occurrences"`
+  Velocity float64 `json:"velocity"`
+  Series []int `json:"series"`
+  Sources []Source `json:"sources"`
+}
+
+type Topics []Topic
\ No newline at end of file
@@ -6,11 +6,22 @@ import (
 )
 
 type WordCount struct {
+  // When stemming, Term is the most used of the forms merged into Stem, so
+  // that it can be looked up as a term of its own
   Term string `json:"term"`
+  Stem string `json:"stem,omitempty"`
+  Ngram int `json:"ngram"`
   Occurrences  int `json:"occurrences"`
   Velocity float64 `json:"velocity"`
+  // The burst score trends are ranked by and the baseline it was measured
+  // against, see Scorer
+  Algorithm string `json:"algorithm"`
+  Score float64 `json:"score"`
+  Baseline float64 `json:"baseline"`
   Series []int `json:"series"`
   Sequence int `json:"sequence"`
+  // The surface forms merged into Term when stemming or by aliases
+  Forms TermForms `json:"forms,omitempty"`
 }
 
 type WordCounts []WordCount
@@ -25,7 +36,7 @@ func (sm *sortedWordCountMap) Len() int {
 }
 
 func (sm *sortedWordCountMap) Less(i, j int) bool {
-    a, b := sm.m[sm.s[i]].Velocity, sm.m[sm.s[j]].Velocity
+    a, b := sm.m[sm.s[i]].Score, sm.m[sm.s[j]].Score
     if a != b {
         // Order by decreasing value.
         return a > b
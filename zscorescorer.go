@@ -0,0 +1,30 @@
+package main
+
+import (
+  "math"
+)
+
+// Scores the last interval by how many standard deviations it lies above
+// the mean of the trailing intervals.
+type ZScoreScorer struct{}
+
+func (ZScoreScorer) Score(series []int) Score {
+  current, baseline := splitSeries(series)
+  if len(baseline) == 0 {
+    return Score{Value: current / countDeviation(0, 0)}
+  }
+
+  mean := 0.0
+  for _, count := range baseline {
+    mean += count
+  }
+  mean = mean / float64(len(baseline))
+
+  variance := 0.0
+  for _, count := range baseline {
+    variance += (count - mean) * (count - mean)
+  }
+  variance = variance / float64(len(baseline))
+
+  return Score{Value: (current - mean) / countDeviation(math.Sqrt(variance), mean), Baseline: mean}
+}
\ No newline at end of file